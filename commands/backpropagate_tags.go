@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/spotify"
+)
+
+func init() {
+	Register(Command{
+		Name:         "backpropagate-tags",
+		Description:  "Add and remove Spotify playlist tracks so playlists mirror local Genre tags",
+		Mutates:      true,
+		NeedsService: true,
+		Run: func(ctx context.Context, args []string) error {
+			return BackpropagateTags(ctx)
+		},
+	})
+}
+
+// BackpropagateTags pushes local Genre tags back to the matching Spotify playlists.
+func BackpropagateTags(ctx context.Context) error {
+	graph, err := spotify.GetTrackGraph(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	contexts := music.FilesToFileContexts(ctx, files)
+	contexts = music.HydrateSpotifyOnContexts(ctx, contexts, graph)
+
+	didChange, err := music.BackpropagateTags(ctx, graph, contexts)
+	if err != nil {
+		return err
+	}
+	if !didChange {
+		return nil
+	}
+	log.Info("Refreshing Spotify cache after updating playlists")
+	return spotify.CreateGraphCache(ctx)
+}
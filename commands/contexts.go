@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/state"
+	"github.com/snikch/musicmanager/types"
+)
+
+// loadITunesLibrary loads the iTunes library XML from the configured iTunes directory.
+func loadITunesLibrary(ctx context.Context) (*itunes.Library, error) {
+	loc := configuration.ContextConfiguration(ctx).ITunes.Dir + "iTunes Music Library.xml"
+	return itunes.LoadLibrary(loc)
+}
+
+// withStateStore loads the recording state store onto the context, marking the recordings of the supplied local
+// files as Have.
+func withStateStore(ctx context.Context, local []types.RecordingKey) (context.Context, *state.Store, error) {
+	store, err := state.Load()
+	if err != nil {
+		return ctx, nil, err
+	}
+	if marked := store.MarkHave(local); marked > 0 {
+		log.WithField("recordings", marked).Info("Marked local recordings as have")
+	}
+	return state.ContextWithStore(ctx, store), store, nil
+}
+
+// saveStateStore persists the state store, unless this is a dry run.
+func saveStateStore(ctx context.Context, store *state.Store) error {
+	if plan.DryRun(ctx) {
+		return nil
+	}
+	return store.Save()
+}
+
+// loadFileContexts loads all local files and joins them with the Spotify graph and iTunes library.
+func loadFileContexts(ctx context.Context) (spotify.TrackGraph, types.FileContexts, error) {
+	graph, _, contexts, err := loadFilesAndContexts(ctx)
+	return graph, contexts, err
+}
+
+// loadFilesAndContexts loads all local files and joins them with the Spotify graph and iTunes library, returning the
+// files as well as the contexts, which only hold one file per song.
+func loadFilesAndContexts(ctx context.Context) (spotify.TrackGraph, []types.File, types.FileContexts, error) {
+	graph, err := spotify.GetTrackGraph(ctx)
+	if err != nil {
+		return graph, nil, nil, err
+	}
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return graph, nil, nil, err
+	}
+	library, err := loadITunesLibrary(ctx)
+	if err != nil {
+		return graph, nil, nil, err
+	}
+	contexts := music.FilesToFileContexts(ctx, files)
+	contexts = music.HydrateSpotifyOnContexts(ctx, contexts, graph)
+	contexts = music.HydrateITunesOnContexts(ctx, contexts, library)
+	catalog := music.LinkCatalog(ctx, files, graph, library)
+	contexts = music.HydrateCatalogOnContexts(ctx, contexts, catalog, graph)
+	return graph, files, contexts, nil
+}
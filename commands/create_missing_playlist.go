@@ -2,12 +2,34 @@ package commands
 
 import (
 	"context"
+	"flag"
 
+	"github.com/snikch/musicmanager/configuration"
 	"github.com/snikch/musicmanager/music"
 
 	"github.com/snikch/musicmanager/spotify"
 )
 
+// missingPlaylistName is set by the -playlist flag of create-missing-playlist.
+var missingPlaylistName string
+
+func init() {
+	Register(Command{
+		Name:         "create-missing-playlist",
+		Description:  "Create a Spotify playlist of playlist tracks that aren't present locally",
+		Mutates:      true,
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.StringVar(&missingPlaylistName, "playlist", "", "name of a playlist to fill instead of Spotify.OutputPlaylist, created if it doesn't exist")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return CreateMissingPlaylist(ctx)
+		},
+	})
+}
+
+// CreateMissingPlaylist fills the output playlist with every playlist track that has no local file, excluding
+// recordings marked Don't Want.
 func CreateMissingPlaylist(ctx context.Context) error {
 	graph, err := spotify.GetTrackGraph(ctx)
 	if err != nil {
@@ -17,5 +39,14 @@ func CreateMissingPlaylist(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	return spotify.CreateMissingPlaylist(ctx, graph, music.LeftOuterJoinFilesToGraph(ctx, files, graph))
+	ctx, store, err := withStateStore(ctx, music.LocalRecordings(files))
+	if err != nil {
+		return err
+	}
+	missing := music.ExcludeUnwanted(ctx, music.LeftOuterJoinFilesToGraph(ctx, files, graph))
+	err = spotify.CreateMissingPlaylist(ctx, graph, missing, missingPlaylistName)
+	if err != nil {
+		return err
+	}
+	return saveStateStore(ctx, store)
 }
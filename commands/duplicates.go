@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+)
+
+// keepBest is set by the -keep-best flag of duplicates.
+var keepBest bool
+
+func init() {
+	Register(Command{
+		Name:        "duplicates",
+		Description: "Find local files that are the same song or the same audio, and optionally move all but the best copy aside",
+		Mutates:     true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.BoolVar(&keepBest, "keep-best", false, "move every copy but the best of each duplicate into the rejects dir")
+			fs.StringVar(&conf.MusicFiles.RejectsDir, "rejects-dir", conf.MusicFiles.RejectsDir, "directory to move duplicates into")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return Duplicates(ctx, keepBest)
+		},
+	})
+}
+
+// Duplicates reports every group of duplicate local files, best copy first, and optionally moves the other copies
+// into the rejects dir.
+func Duplicates(ctx context.Context, keep bool) error {
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	groups := music.FindDuplicates(files)
+	err = writeDuplicates(os.Stdout, groups)
+	if err != nil || !keep {
+		return err
+	}
+	dir := configuration.ContextConfiguration(ctx).MusicFiles.RejectsDir
+	if dir == "" {
+		return errors.New("no MusicFiles.RejectsDir configured to move duplicates into")
+	}
+	moved, err := music.KeepBestDuplicates(ctx, groups, dir)
+	if err != nil {
+		return err
+	}
+	log.WithField("files", moved).WithField("dir", dir).Info("Moved duplicate files")
+	return nil
+}
+
+func writeDuplicates(w io.Writer, groups []music.DuplicateGroup) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP\tMATCH\tKEEP\tBITRATE\tLENGTH\tPATH")
+	for i, group := range groups {
+		for j, dupe := range group.Files {
+			keep := ""
+			if j == 0 {
+				keep = "*"
+			}
+			bitrate := "-"
+			if dupe.Bitrate > 0 {
+				bitrate = fmt.Sprintf("%dkbps", dupe.Bitrate)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				i+1,
+				strings.Join(group.Reasons, ","),
+				keep,
+				bitrate,
+				formatLength(dupe.Duration),
+				dupe.File.Path(),
+			)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d duplicate groups\n", len(groups))
+	return tw.Flush()
+}
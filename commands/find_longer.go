@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+const defaultLongerPlaylist = "Longer Versions"
+
+var (
+	// createLongerPlaylist is set by the -playlist flag of find-longer.
+	createLongerPlaylist bool
+	// longerPlaylistName is set by the -playlist-name flag of find-longer.
+	longerPlaylistName string
+)
+
+func init() {
+	Register(Command{
+		Name:         "find-longer",
+		Description:  "Find extended, original and club mixes on Spotify that are longer than your versions",
+		Mutates:      true,
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.BoolVar(&createLongerPlaylist, "playlist", false, "also fill a Spotify playlist with the longer versions")
+			fs.StringVar(&longerPlaylistName, "playlist-name", "", "name of a playlist to fill instead of Spotify.LongerPlaylist, created if it doesn't exist")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return FindLonger(ctx)
+		},
+	})
+}
+
+// longerVersion is a track that has a longer version available on Spotify.
+type longerVersion struct {
+	Source   string
+	Key      types.SongKey
+	Duration int
+	Longer   spotifyapi.FullTrack
+}
+
+// FindLonger reports every Spotify playlist track and local file that has a longer mix available on Spotify, and
+// optionally adds the longer versions to a playlist.
+func FindLonger(ctx context.Context) error {
+	graph, err := spotify.GetTrackGraph(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	conf := configuration.ContextConfiguration(ctx)
+	contexts := music.FilesToFileContexts(ctx, files)
+	contexts = music.HydrateSpotifyOnContexts(ctx, contexts, graph)
+	library, err := loadITunesLibrary(ctx)
+	if err != nil {
+		log.WithError(err).Warn("Could not load iTunes library, local files without a Spotify match use their audio length")
+	} else {
+		contexts = music.HydrateITunesOnContexts(ctx, contexts, library)
+	}
+
+	found := []longerVersion{}
+	longer := spotify.TrackLookup{}
+	check := func(source string, key types.SongKey, duration int) error {
+		track, err := spotify.FindLongerVersion(ctx, key, duration)
+		if err != nil || track == nil {
+			return err
+		}
+		log.WithField("key", key).WithField("longer", track.Name).Info("Found longer version")
+		found = append(found, longerVersion{
+			Source:   source,
+			Key:      key,
+			Duration: duration,
+			Longer:   *track,
+		})
+		longer[key] = *track
+		return nil
+	}
+	for key, track := range graph.Tracks {
+		err := check("spotify", key, track.Duration)
+		if err != nil {
+			return err
+		}
+	}
+	for key, fileContext := range contexts {
+		// Files matched to a playlist track were already checked above.
+		if fileContext.SpotifyTrack != nil || key.Artist == "" || key.Title == "" {
+			continue
+		}
+		duration := int(music.FileDuration(fileContext.File) / time.Millisecond)
+		if fileContext.ITunesTrack != nil {
+			duration = fileContext.ITunesTrack.TotalTime
+		}
+		// Without a length any mix would count as longer.
+		if duration <= 0 {
+			continue
+		}
+		err := check("local", key, duration)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeLongerReport(found)
+	if err != nil {
+		return err
+	}
+	if !createLongerPlaylist {
+		return nil
+	}
+	if conf.Spotify.LongerPlaylist.Name == "" {
+		conf.Spotify.LongerPlaylist.Name = defaultLongerPlaylist
+	}
+	output, err := spotify.NamedPlaylist(ctx, &conf.Spotify.LongerPlaylist, longerPlaylistName)
+	if err != nil {
+		return err
+	}
+	return spotify.FillPlaylist(ctx, graph, output, longer)
+}
+
+func writeLongerReport(found []longerVersion) error {
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Key.Artist != found[j].Key.Artist {
+			return found[i].Key.Artist < found[j].Key.Artist
+		}
+		return found[i].Key.Title < found[j].Key.Title
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tARTIST\tTITLE\tLENGTH\tLONGER VERSION\tLENGTH\tSPOTIFY ID")
+	for _, version := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			version.Source,
+			version.Key.Artist,
+			version.Key.Title,
+			formatDuration(version.Duration),
+			version.Longer.Name,
+			formatDuration(version.Longer.Duration),
+			version.Longer.ID,
+		)
+	}
+	return w.Flush()
+}
+
+// formatDuration formats a duration in milliseconds as m:ss, or - if unknown.
+func formatDuration(ms int) string {
+	if ms <= 0 {
+		return "-"
+	}
+	d := time.Duration(ms) * time.Millisecond
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -2,39 +2,65 @@ package follow_artists
 
 import (
 	"context"
+	"flag"
 	"strings"
 
-	"github.com/snikch/api/fail"
 	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/commands"
 	"github.com/snikch/musicmanager/configuration"
 	"github.com/snikch/musicmanager/itunes"
-	"github.com/snikch/musicmanager/spotifyclient"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
 	"github.com/zmb3/spotify"
 )
 
 const (
 	concurrency = 2
+	// defaultMinRating is the star rating an artist needs when ITunes.Artists.MinRating isn't configured.
+	defaultMinRating = 3
 )
 
-// Command ensures all artists with a 3 star rating or higher are followed on spotify.
+func init() {
+	commands.Register(commands.Command{
+		Name:         "follow-artists",
+		Description:  "Follow Spotify artists of highly rated iTunes tracks",
+		Mutates:      true,
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.IntVar(&conf.ITunes.Artists.MinRating, "rating", conf.ITunes.Artists.MinRating, "minimum star rating of a track to follow its artists (default 3)")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return Command(ctx)
+		},
+	})
+}
+
+// Command ensures all artists with a 3 star rating (or ITunes.Artists.MinRating) or higher are followed on spotify.
 func Command(ctx context.Context) error {
-	loc := configuration.ContextConfiguration(ctx).ITunes.Dir + "iTunes Music Library.xml"
-	library, err := itunes.LoadLibrary(loc)
+	conf := configuration.ContextConfiguration(ctx)
+	library, err := itunes.LoadLibrary(conf.ITunes.Dir + "iTunes Music Library.xml")
 	if err != nil {
 		return err
 	}
-	tracks := itunes.FilterRating(library.Tracks, 3)
+	rating := conf.ITunes.Artists.MinRating
+	if rating == 0 {
+		rating = defaultMinRating
+	}
+	// iTunes stores a 1 star rating as 20, 2 -> 40 etc.
+	tracks := itunes.FilterRating(library.Tracks, rating*20)
 	artists := itunes.ReduceArtists(tracks)
 	log.
 		WithField("tracks", len(tracks)).
 		WithField("artists", len(artists)).
-		Info("Found tracks with rating 3+")
+		WithField("rating", rating).
+		Info("Found tracks with minimum rating")
 	manager := newFollowManager(ctx)
 	return manager.ensureFollowed(ctx, artists)
 }
 
 type followManager struct {
-	client          *spotify.Client
+	service         service.Service
 	conf            *configuration.Configuration
 	skipLookup      map[string]bool
 	followingLookup map[string]bool
@@ -42,7 +68,7 @@ type followManager struct {
 
 func newFollowManager(ctx context.Context) *followManager {
 	manager := &followManager{
-		client:     spotifyclient.ContextClient(ctx),
+		service:    service.ContextService(ctx),
 		conf:       configuration.ContextConfiguration(ctx),
 		skipLookup: map[string]bool{},
 	}
@@ -69,14 +95,23 @@ func (manager *followManager) ensureFollowed(ctx context.Context, names []string
 		}
 		if id != nil {
 			toFollow = append(toFollow, *id)
+			if p := plan.ContextPlan(ctx); p != nil {
+				p.Add(plan.Action{
+					Kind:    plan.KindFollowArtist,
+					Target:  name,
+					Details: map[string]string{"id": id.String()},
+				})
+			}
 		}
 	}
 	if len(toFollow) == 0 {
 		log.Info("No artists to follow")
 		return nil
 	}
+	if plan.DryRun(ctx) {
+		return nil
+	}
 	log.WithField("count", len(toFollow)).Info("Artists to follow")
-	client := spotifyclient.ContextClient(ctx)
 	cursor := 0
 	for {
 		head := cursor
@@ -86,9 +121,15 @@ func (manager *followManager) ensureFollowed(ctx context.Context, names []string
 		}
 		log.WithField("count", tail-head).Info("Following artists")
 		// We can only follow in lots of 50
-		err := client.FollowArtist(toFollow[head:tail]...)
+		err := manager.service.FollowArtists(toFollow[head:tail]...)
 		if err != nil {
-			return fail.Trace(err)
+			return err
+		}
+		for _, id := range toFollow[head:tail] {
+			journal.Record(ctx, journal.Entry{
+				Kind:     plan.KindFollowArtist,
+				ArtistID: string(id),
+			})
 		}
 		// If we've hit the end, break out
 		if tail-head < 50 || tail == len(toFollow) {
@@ -120,15 +161,15 @@ func (manager *followManager) shouldFollow(ctx context.Context, name string) (*s
 		l.Debug("Already following")
 		return nil, nil
 	}
-	result, err := manager.client.Search(name, spotify.SearchTypeArtist)
+	results, err := manager.service.SearchArtists(name)
 	if err != nil {
-		return nil, fail.Trace(err)
+		return nil, err
 	}
-	if len(result.Artists.Artists) == 0 {
+	if len(results) == 0 {
 		l.Warn("Could not find Spotify artist")
 		return nil, nil
 	}
-	spotifyArtist := result.Artists.Artists[0]
+	spotifyArtist := results[0]
 	l = l.WithField("Spotify artist", spotifyArtist.Name)
 	if strings.ToLower(spotifyArtist.Name) != name {
 		l.
@@ -149,22 +190,7 @@ func splitNames(names []string) []string {
 }
 
 func getFollowing(ctx context.Context) ([]spotify.FullArtist, error) {
-	client := spotifyclient.ContextClient(ctx)
-	limit := 50
-	artists := []spotify.FullArtist{}
-	after := ""
-	for {
-		chunk, err := client.CurrentUsersFollowedArtistsOpt(limit, after)
-		if err != nil {
-			return nil, fail.Trace(err)
-		}
-		artists = append(artists, chunk.Artists...)
-		if len(chunk.Artists) < limit {
-			break
-		}
-		after = chunk.Cursor.After
-	}
-	return artists, nil
+	return service.ContextService(ctx).FollowedArtists()
 }
 
 func followingLookup(artists []spotify.FullArtist) map[string]bool {
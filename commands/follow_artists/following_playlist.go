@@ -0,0 +1,274 @@
+package follow_artists
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/commands"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
+	"github.com/zmb3/spotify"
+)
+
+const (
+	releaseDateFormat            = "2006-01-02"
+	defaultFollowingPlaylist     = "FOLLOWING"
+	defaultFollowingLookbackDays = 28
+)
+
+// since overrides the persisted watermark for a single run.
+var since string
+
+func init() {
+	commands.Register(commands.Command{
+		Name:         "create-following-playlist",
+		Description:  "Add new releases from followed Spotify artists to a playlist",
+		Mutates:      true,
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.StringVar(&conf.Spotify.FollowingPlaylist.Name, "playlist", conf.Spotify.FollowingPlaylist.Name, "name of the playlist to add releases to (default FOLLOWING)")
+			fs.StringVar(&since, "since", "", "only add releases from this date (YYYY-MM-DD) instead of the saved watermark")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return FollowingPlaylistCommand(ctx)
+		},
+	})
+}
+
+// FollowingPlaylistCommand adds every track released by a followed artist since the last run to the following
+// playlist, then moves the watermark to the date of this run. Releases dated on the day of the watermark are checked
+// again on the next run, in case they appear on Spotify later in the day, and tracks already in the playlist are
+// skipped.
+func FollowingPlaylistCommand(ctx context.Context) error {
+	conf := configuration.ContextConfiguration(ctx)
+	after, err := releasedAfter(conf)
+	if err != nil {
+		return err
+	}
+	// The date of this run in local time, as release dates have no time zone.
+	watermark := time.Now().Format(releaseDateFormat)
+	following, err := getFollowing(ctx)
+	if err != nil {
+		return err
+	}
+	log.
+		WithField("artists", len(following)).
+		WithField("from", after.Format(releaseDateFormat)).
+		Info("Finding new releases from followed artists")
+	albums, err := newReleases(ctx, following, after)
+	if err != nil {
+		return err
+	}
+	svc := service.ContextService(ctx)
+	tracks := []spotify.SimpleTrack{}
+	seen := map[spotify.ID]bool{}
+	for _, album := range albums {
+		albumTracks := album.Tracks.Tracks
+		if len(albumTracks) < album.Tracks.Total {
+			albumTracks, err = svc.AlbumTracks(album.ID)
+			if err != nil {
+				return err
+			}
+		}
+		for _, track := range albumTracks {
+			if seen[track.ID] {
+				continue
+			}
+			seen[track.ID] = true
+			tracks = append(tracks, track)
+			log.
+				WithField("album", album.Name).
+				WithField("released", album.ReleaseDate).
+				WithField("name", track.Name).
+				WithField("artist", strings.Join(simpleArtistNames(track.Artists), ", ")).
+				Info("Found new release track")
+		}
+	}
+	if len(tracks) == 0 {
+		log.Info("No new releases")
+	}
+	added, err := addToFollowingPlaylist(ctx, conf, tracks)
+	if err != nil {
+		return err
+	}
+	if plan.DryRun(ctx) {
+		return nil
+	}
+	conf.Spotify.FollowingPlaylist.ReleasedAfter = watermark
+	log.
+		WithField("tracks", added).
+		WithField("watermark", conf.Spotify.FollowingPlaylist.ReleasedAfter).
+		Info("Following playlist updated")
+	return nil
+}
+
+// releasedAfter returns the date releases must be on or after, from the -since flag, the persisted watermark or a
+// default lookback period on the first run.
+func releasedAfter(conf *configuration.Configuration) (time.Time, error) {
+	value := since
+	if value == "" {
+		value = conf.Spotify.FollowingPlaylist.ReleasedAfter
+	}
+	if value == "" {
+		return time.Now().AddDate(0, 0, -defaultFollowingLookbackDays).Truncate(24 * time.Hour), nil
+	}
+	after, err := time.Parse(releaseDateFormat, value)
+	if err != nil {
+		return after, fail.Trace(err)
+	}
+	return after, nil
+}
+
+// newReleases returns the full albums and singles of the supplied artists released on or after the supplied date.
+func newReleases(ctx context.Context, artists []spotify.FullArtist, after time.Time) ([]*spotify.FullAlbum, error) {
+	svc := service.ContextService(ctx)
+	ids := make(chan spotify.ID)
+	wg := &sync.WaitGroup{}
+	mu := &sync.Mutex{}
+	albumIDs := []spotify.ID{}
+	seen := map[spotify.ID]bool{}
+	var firstErr error
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				artistAlbums, err := svc.ArtistAlbums(id)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				for _, album := range artistAlbums {
+					if !seen[album.ID] {
+						seen[album.ID] = true
+						albumIDs = append(albumIDs, album.ID)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, artist := range artists {
+		ids <- artist.ID
+	}
+	close(ids)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	full, err := svc.Albums(albumIDs...)
+	if err != nil {
+		return nil, err
+	}
+	albums := []*spotify.FullAlbum{}
+	for _, album := range full {
+		if album == nil || album.ReleaseDateTime().Before(after) {
+			continue
+		}
+		albums = append(albums, album)
+	}
+	return albums, nil
+}
+
+// addToFollowingPlaylist appends the tracks that aren't already in the following playlist to it, creating it if it
+// doesn't exist yet. It returns how many tracks were added.
+func addToFollowingPlaylist(ctx context.Context, conf *configuration.Configuration, tracks []spotify.SimpleTrack) (int, error) {
+	name := conf.Spotify.FollowingPlaylist.Name
+	if name == "" {
+		name = defaultFollowingPlaylist
+	}
+	svc := service.ContextService(ctx)
+	userID, err := svc.CurrentUser()
+	if err != nil {
+		return 0, err
+	}
+	playlistID := spotify.ID(conf.Spotify.FollowingPlaylist.ID)
+	if playlistID != "" {
+		existing, err := svc.PlaylistTracks(userID, playlistID)
+		if err != nil {
+			return 0, err
+		}
+		added := map[spotify.ID]bool{}
+		for _, track := range existing {
+			added[track.ID] = true
+		}
+		missing := []spotify.SimpleTrack{}
+		for _, track := range tracks {
+			if !added[track.ID] {
+				missing = append(missing, track)
+			}
+		}
+		tracks = missing
+	}
+	if p := plan.ContextPlan(ctx); p != nil {
+		if conf.Spotify.FollowingPlaylist.ID == "" {
+			p.Add(plan.Action{Kind: plan.KindPlaylistCreate, Target: name})
+		}
+		for _, track := range tracks {
+			p.Add(plan.Action{
+				Kind:   plan.KindPlaylistAdd,
+				Target: strings.Join(simpleArtistNames(track.Artists), ", ") + " - " + track.Name,
+				Details: map[string]string{
+					"playlist": name,
+					"trackID":  track.ID.String(),
+				},
+			})
+		}
+		return len(tracks), nil
+	}
+	if len(tracks) == 0 {
+		return 0, nil
+	}
+	if playlistID == "" {
+		playlistID, err = svc.CreatePlaylist(userID, name)
+		if err != nil {
+			return 0, err
+		}
+		log.
+			WithField("user", userID).
+			WithField("name", name).
+			Info("Created new spotify following playlist")
+		conf.Spotify.FollowingPlaylist.ID = string(playlistID)
+	}
+	ids := make([]spotify.ID, len(tracks))
+	for i := range tracks {
+		ids[i] = tracks[i].ID
+	}
+	// We can only add in lots of 100
+	for head := 0; head < len(ids); head += 100 {
+		tail := head + 100
+		if tail > len(ids) {
+			tail = len(ids)
+		}
+		err := svc.AddPlaylistTracks(userID, playlistID, ids[head:tail]...)
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range ids[head:tail] {
+			journal.Record(ctx, journal.Entry{
+				Kind:         plan.KindPlaylistAdd,
+				UserID:       userID,
+				PlaylistID:   string(playlistID),
+				PlaylistName: name,
+				TrackID:      string(id),
+			})
+		}
+	}
+	return len(tracks), nil
+}
+
+func simpleArtistNames(artists []spotify.SimpleArtist) []string {
+	names := make([]string, len(artists))
+	for i := range artists {
+		names[i] = artists[i].Name
+	}
+	return names
+}
@@ -0,0 +1,53 @@
+package follow_artists
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/service"
+	"github.com/zmb3/spotify"
+)
+
+func TestFollowingPlaylistAddsSameDayReleasesOnce(t *testing.T) {
+	today := time.Now().Format(releaseDateFormat)
+	fake := service.NewFake("user")
+	artist := spotify.FullArtist{}
+	artist.ID = "artist"
+	fake.Artists = []spotify.FullArtist{artist}
+	fake.Following["artist"] = true
+	album := &spotify.FullAlbum{ReleaseDate: today}
+	album.ID = "album"
+	album.Artists = []spotify.SimpleArtist{{ID: "artist"}}
+	for _, id := range []spotify.ID{"added", "late"} {
+		track := spotify.SimpleTrack{}
+		track.ID = id
+		album.Tracks.Tracks = append(album.Tracks.Tracks, track)
+	}
+	album.Tracks.Total = 2
+	fake.AlbumsByID["album"] = album
+	added := spotify.FullTrack{}
+	added.ID = "added"
+	playlist := fake.AddPlaylist("FOLLOWING", added)
+
+	ctx := service.ContextWithService(configuration.ContextWithConfiguration(context.Background()), fake)
+	conf := configuration.ContextConfiguration(ctx)
+	conf.Spotify.FollowingPlaylist.ID = string(playlist.ID)
+	// The last run was earlier today, before the late track appeared.
+	conf.Spotify.FollowingPlaylist.ReleasedAfter = today
+	for i := 0; i < 2; i++ {
+		err := FollowingPlaylistCommand(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []spotify.ID{"added", "late"}
+	if got := fake.PlaylistTrackIDs[playlist.ID]; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected playlist %v, got %v", expected, got)
+	}
+	if conf.Spotify.FollowingPlaylist.ReleasedAfter != today {
+		t.Fatalf("Expected the watermark to be today, got %s", conf.Spotify.FollowingPlaylist.ReleasedAfter)
+	}
+}
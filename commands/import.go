@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+)
+
+// importMove is set by the -move flag of import.
+var importMove bool
+
+func init() {
+	Register(Command{
+		Name:        "import",
+		Description: "Classify a directory of new downloads against the library as new, duplicate, longer or shorter",
+		Usage:       "<dir>",
+		Mutates:     true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.BoolVar(&importMove, "move", false, "move new and longer files into the import dir, and duplicates into the rejects dir")
+			fs.StringVar(&conf.MusicFiles.ImportDir, "import-dir", conf.MusicFiles.ImportDir, "directory to move new files into (default the first of MusicFiles.Dirs)")
+			fs.StringVar(&conf.MusicFiles.RejectsDir, "rejects-dir", conf.MusicFiles.RejectsDir, "directory to move duplicates into")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return errors.New("import requires a single directory")
+			}
+			return Import(ctx, args[0], importMove)
+		},
+	})
+}
+
+// Import classifies every file in the directory against the library, and optionally moves them.
+func Import(ctx context.Context, dir string, move bool) error {
+	library, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := music.ClassifyDir(ctx, dir, library)
+	if err != nil {
+		return err
+	}
+	err = writeImportReport(os.Stdout, results)
+	if err != nil || !move {
+		return err
+	}
+	return music.ImportFiles(ctx, results)
+}
+
+func writeImportReport(w io.Writer, results []music.ImportResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLASS\tFILE\tLENGTH\tEXISTING\tLENGTH")
+	for _, result := range results {
+		existing := ""
+		if result.Existing != nil {
+			existing = result.Existing.Path()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			result.Class,
+			result.File.Filename,
+			formatLength(result.Duration),
+			existing,
+			formatLength(result.ExistingDuration),
+		)
+	}
+	return tw.Flush()
+}
+
+// formatLength formats a duration as m:ss, or - if unknown.
+func formatLength(d time.Duration) string {
+	return formatDuration(int(d / time.Millisecond))
+}
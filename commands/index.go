@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/music"
+)
+
+func init() {
+	Register(Command{
+		Name:        "index",
+		Description: "Rebuild the library index by parsing every local file again",
+		Usage:       "rebuild",
+		Run: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || args[0] != "rebuild" {
+				return errors.New("index requires the rebuild argument")
+			}
+			return RebuildIndex(ctx)
+		},
+	})
+}
+
+// RebuildIndex discards the library index and parses every local file.
+func RebuildIndex(ctx context.Context) error {
+	files, err := music.RebuildIndex(ctx)
+	if err != nil {
+		return err
+	}
+	log.WithField("files", len(files)).Info("Rebuilt library index")
+	return nil
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+)
+
+// linkSongsVerbose is set by the -v flag of link-songs.
+var linkSongsVerbose bool
+
+func init() {
+	Register(Command{
+		Name:        "link-songs",
+		Description: "Group local files, Spotify and iTunes tracks into songs and their recordings",
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.BoolVar(&linkSongsVerbose, "v", false, "list every song that has more than one recording")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return LinkSongs(ctx)
+		},
+	})
+}
+
+// LinkSongs builds the song catalog, saves it to the cache and prints a summary.
+func LinkSongs(ctx context.Context) error {
+	graph, err := spotify.GetTrackGraph(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	library, err := loadITunesLibrary(ctx)
+	if err != nil {
+		return err
+	}
+	catalog := music.LinkCatalog(ctx, files, graph, library)
+	if err := catalog.Save(music.CatalogLoc); err != nil {
+		return fail.Trace(err)
+	}
+	log.WithField("loc", music.CatalogLoc).Info("Saved song catalog")
+	return writeCatalogSummary(os.Stdout, catalog, linkSongsVerbose)
+}
+
+func writeCatalogSummary(w io.Writer, catalog *types.Catalog, verbose bool) error {
+	recordings := 0
+	grouped := []*types.Composition{}
+	for _, composition := range catalog.Compositions {
+		recordings += len(composition.Recordings)
+		if len(composition.Recordings) > 1 {
+			grouped = append(grouped, composition)
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Songs\t%d\n", len(catalog.Compositions))
+	fmt.Fprintf(tw, "Recordings\t%d\n", recordings)
+	fmt.Fprintf(tw, "Songs with more than one recording\t%d\n", len(grouped))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if !verbose {
+		return nil
+	}
+	sort.Slice(grouped, func(i, j int) bool {
+		return songKeyString(grouped[i].Key) < songKeyString(grouped[j].Key)
+	})
+	for _, composition := range grouped {
+		fmt.Fprintf(w, "\n%s\n", songKeyString(composition.Key))
+		names := make([]string, 0, len(composition.Recordings))
+		for name := range composition.Recordings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			recording := composition.Recordings[name]
+			if name == "" {
+				name = "(no label)"
+			}
+			services := map[string]int{}
+			for _, instance := range recording.Instances() {
+				services[instance.Service]++
+			}
+			fmt.Fprintf(w, "  %s: %d releases, %d local, %d spotify, %d itunes\n", name, len(recording.Tracks),
+				services[types.ServiceLocal], services[types.ServiceSpotify], services[types.ServiceITunes])
+		}
+	}
+	return nil
+}
+
+func songKeyString(key types.SongKey) string {
+	return key.Artist + " - " + key.Title
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/types"
+)
+
+var listOptions struct {
+	query  string
+	format string
+}
+
+func init() {
+	Register(Command{
+		Name:        "list-tracks",
+		Description: "List local files joined with their Spotify and iTunes tracks",
+		Usage:       "[query]",
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.StringVar(&listOptions.format, "format", "table", "output format: table, json (one object per line) or csv")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return ListTracks(ctx, strings.Join(args, " "), listOptions.format)
+		},
+	})
+}
+
+// trackRow is a single output row of list-tracks.
+type trackRow struct {
+	Path          string
+	Artist        string
+	Title         string
+	Tags          []string
+	Rating        int
+	SpotifyID     string
+	Playlists     []string
+	ITunesTrackID int
+}
+
+// ListTracks prints every local file matching the query, e.g. "tag:vocal rating:3 spotify:missing itunes:has".
+func ListTracks(ctx context.Context, rawQuery, format string) error {
+	query, err := music.ParseQuery(rawQuery)
+	if err != nil {
+		return err
+	}
+	var write func(io.Writer, []trackRow) error
+	switch format {
+	case "table":
+		write = writeTrackTable
+	case "json":
+		write = writeTrackJSON
+	case "csv":
+		write = writeTrackCSV
+	default:
+		return fmt.Errorf("unknown format %q, expected table, json or csv", format)
+	}
+	_, files, contexts, err := loadFilesAndContexts(ctx)
+	if err != nil {
+		return err
+	}
+	rows := []trackRow{}
+	// Every file is listed, including duplicates and untagged files that share a key with another file.
+	for _, fileContext := range music.ContextsOfFiles(contexts, files) {
+		if !query.Match(ctx, fileContext) {
+			continue
+		}
+		rows = append(rows, newTrackRow(ctx, fileContext))
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Path < rows[j].Path
+	})
+	return write(os.Stdout, rows)
+}
+
+func newTrackRow(ctx context.Context, fileContext types.FileWithContext) trackRow {
+	row := trackRow{
+		Path:      fileContext.Path(),
+		Artist:    fileContext.Artist(),
+		Title:     fileContext.Title(),
+		Tags:      strings.Fields(fileContext.Genre()),
+		Rating:    music.Rating(ctx, fileContext),
+		Playlists: []string{},
+	}
+	if fileContext.SpotifyTrack != nil {
+		row.SpotifyID = fileContext.SpotifyTrack.ID.String()
+	}
+	for _, playlist := range fileContext.SpotifyPlaylists {
+		row.Playlists = append(row.Playlists, playlist.Name)
+	}
+	if fileContext.ITunesTrack != nil {
+		row.ITunesTrackID = fileContext.ITunesTrack.TrackID
+	}
+	return row
+}
+
+// strings returns the row as columns for the table and csv formats.
+func (row trackRow) strings() []string {
+	itunesID := ""
+	if row.ITunesTrackID != 0 {
+		itunesID = strconv.Itoa(row.ITunesTrackID)
+	}
+	return []string{
+		row.Path,
+		row.Artist,
+		row.Title,
+		strings.Join(row.Tags, " "),
+		strconv.Itoa(row.Rating),
+		row.SpotifyID,
+		strings.Join(row.Playlists, "; "),
+		itunesID,
+	}
+}
+
+var trackHeader = []string{"PATH", "ARTIST", "TITLE", "TAGS", "RATING", "SPOTIFY ID", "PLAYLISTS", "ITUNES ID"}
+
+func writeTrackTable(w io.Writer, rows []trackRow) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(trackHeader, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row.strings(), "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeTrackJSON(w io.Writer, rows []trackRow) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		err := encoder.Encode(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTrackCSV(w io.Writer, rows []trackRow) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(trackHeader)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		err = cw.Write(row.strings())
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
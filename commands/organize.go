@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/types"
+)
+
+// organizeOptions are set by the flags of organize.
+var organizeOptions struct {
+	itunes        bool
+	relocationMap string
+}
+
+func init() {
+	Register(Command{
+		Name:        "organize",
+		Description: "Rename and move local files into directories from a path template of their tags",
+		Usage:       "[dir]",
+		Mutates:     true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.StringVar(&conf.MusicFiles.Organize.Template, "template", conf.MusicFiles.Organize.Template, "path template of each file (default \""+music.DefaultOrganizeTemplate+"\")")
+			fs.StringVar(&conf.MusicFiles.Organize.Dir, "dir", conf.MusicFiles.Organize.Dir, "directory to organize files into (default the import dir)")
+			fs.StringVar(&conf.ITunes.Dir, "itunes-dir", conf.ITunes.Dir, "directory containing the iTunes Music Library.xml")
+			fs.BoolVar(&organizeOptions.itunes, "itunes", false, "point the iTunes tracks of moved files at their new location")
+			fs.StringVar(&organizeOptions.relocationMap, "relocation-map", "", "write a CSV of every file's old and new path, with its iTunes track, to this file")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			switch len(args) {
+			case 0:
+				return Organize(ctx, "")
+			case 1:
+				return Organize(ctx, args[0])
+			default:
+				return errors.New("organize accepts at most one directory")
+			}
+		},
+	})
+}
+
+// Organize moves the files in the supplied dir, or every local file if it's empty, to their path from the organize
+// template.
+func Organize(ctx context.Context, src string) error {
+	conf := configuration.ContextConfiguration(ctx)
+	template := conf.MusicFiles.Organize.Template
+	if template == "" {
+		template = music.DefaultOrganizeTemplate
+	}
+	dir, err := music.OrganizeDir(ctx)
+	if err != nil {
+		return err
+	}
+	mode := music.ITunesRefuse
+	switch {
+	case organizeOptions.itunes:
+		mode = music.ITunesRelocate
+	case organizeOptions.relocationMap != "":
+		mode = music.ITunesIgnore
+	}
+	// The library is always loaded, so files in it aren't moved without relocating or recording them.
+	library, err := loadITunesLibrary(ctx)
+	switch {
+	case err == nil:
+	case mode == music.ITunesIgnore:
+		log.WithError(err).Warn("Could not load iTunes library, the relocation map won't include iTunes tracks")
+	case mode == music.ITunesRefuse && os.IsNotExist(err):
+		log.WithError(err).Info("No iTunes library found, organizing without it")
+	default:
+		return err
+	}
+	var files []types.File
+	if src == "" {
+		files, err = music.GetAllFiles(ctx)
+	} else {
+		files, err = music.LoadDir(ctx, src)
+	}
+	if err != nil {
+		return err
+	}
+	relocations, err := music.Organize(ctx, files, dir, template, library, mode)
+	if err == music.ErrITunesTracks {
+		return errors.New("files to be moved are in the iTunes library, pass -itunes to point iTunes at their new location or -relocation-map to record the moves")
+	}
+	// Write the map of whatever was moved, even if a later move failed.
+	if organizeOptions.relocationMap != "" && !plan.DryRun(ctx) {
+		if mapErr := writeRelocationMap(organizeOptions.relocationMap, relocations); err == nil {
+			err = mapErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	log.WithField("files", len(relocations)).WithField("dir", dir).Info("Organized files")
+	return nil
+}
+
+// writeRelocationMap writes the old and new path of every moved file as CSV, with the iTunes database and persistent
+// IDs of those in the library, so other library apps can be pointed at the new locations.
+func writeRelocationMap(loc string, relocations []music.Relocation) error {
+	file, err := os.Create(loc)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"from", "to", "itunes_database_id", "itunes_persistent_id"})
+	for _, relocation := range relocations {
+		databaseID, persistentID := "", ""
+		if relocation.ITunesTrack != nil {
+			databaseID = strconv.Itoa(relocation.ITunesTrack.TrackID)
+			persistentID = relocation.ITunesTrack.PersistentID
+		}
+		w.Write([]string{relocation.From, relocation.To, databaseID, persistentID})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+)
+
+func init() {
+	Register(Command{
+		Name:        "quality",
+		Description: "Report the bitrate, bitrate mode, sample rate and length of local MP3 files, lowest bitrate first",
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.IntVar(&conf.MusicFiles.LowBitrate.Threshold, "threshold", conf.MusicFiles.LowBitrate.Threshold, "only list files below this bitrate in kbps")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return QualityReport(ctx)
+		},
+	})
+}
+
+// QualityReport prints the audio quality of every local MP3 file, or only those below the low bitrate threshold if
+// one is set.
+func QualityReport(ctx context.Context) error {
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	_, threshold := music.LowBitrateThreshold(ctx)
+	return writeQuality(os.Stdout, music.BuildQuality(files, threshold), threshold)
+}
+
+func writeQuality(w io.Writer, quality music.Quality, threshold int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BITRATE\tMODE\tSAMPLE RATE\tLENGTH\tPATH")
+	for _, file := range quality.Files {
+		if threshold > 0 && file.Bitrate >= threshold {
+			break
+		}
+		fmt.Fprintf(tw, "%dkbps\t%s\t%.1fkHz\t%s\t%s\n",
+			file.Bitrate,
+			file.BitrateMode,
+			float64(file.SampleRate)/1000,
+			formatLength(file.Duration),
+			file.Path(),
+		)
+	}
+	fmt.Fprintln(tw)
+	modes := make([]string, 0, len(quality.Modes))
+	for mode := range quality.Modes {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	for _, mode := range modes {
+		fmt.Fprintf(tw, "%s\t%d files\n", mode, quality.Modes[mode])
+	}
+	if threshold > 0 {
+		fmt.Fprintf(tw, "Below %dkbps\t%d files\n", threshold, quality.Low)
+	}
+	return tw.Flush()
+}
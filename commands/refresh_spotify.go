@@ -2,10 +2,26 @@ package commands
 
 import (
 	"context"
+	"flag"
 
+	"github.com/snikch/musicmanager/configuration"
 	"github.com/snikch/musicmanager/spotify"
 )
 
+func init() {
+	Register(Command{
+		Name:         "refresh-spotify",
+		Description:  "Pull matching playlists from Spotify into the local cache",
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.StringVar(&conf.Spotify.PlaylistRegex, "playlist-regex", conf.Spotify.PlaylistRegex, "only cache playlists with names matching this regex")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return RefreshSpotify(ctx)
+		},
+	})
+}
+
 // RefreshSpotify retrieves all playlists from spotify and stores them in a cache.
 func RefreshSpotify(ctx context.Context) error {
 	return spotify.CreateGraphCache(ctx)
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
+	"github.com/snikch/musicmanager/spotifyclient"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+// Command is a single musicmanager subcommand.
+type Command struct {
+	// Name is the argument used to invoke the command, e.g. tag-files.
+	Name string
+	// Description is a one line summary shown in the command list.
+	Description string
+	// Usage describes any positional arguments, e.g. "<dir>".
+	Usage string
+	// Mutates marks commands that change files, Spotify or iTunes. These accept -dry-run and -format.
+	Mutates bool
+	// NeedsService marks commands that call Spotify, which are given a Spotify client. Other commands run offline,
+	// reading Spotify playlists from the cache if at all.
+	NeedsService bool
+	// Flags registers any command specific flags. Flags may be bound directly to configuration values, in which
+	// case they override the configuration for this run only.
+	Flags func(fs *flag.FlagSet, conf *configuration.Configuration)
+	// Run executes the command with any remaining positional arguments.
+	Run func(ctx context.Context, args []string) error
+}
+
+var registry = map[string]Command{}
+
+// Register adds a command to the registry. It panics if the name is already taken.
+func Register(command Command) {
+	if _, exists := registry[command.Name]; exists {
+		panic("commands: duplicate command " + command.Name)
+	}
+	registry[command.Name] = command
+}
+
+// All returns every registered command, ordered by name.
+func All() []Command {
+	all := make([]Command, 0, len(registry))
+	for _, command := range registry {
+		all = append(all, command)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// Run parses the supplied arguments, runs the matching command and returns the exit code for the process.
+func Run(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		displayHelp(os.Stderr)
+		return ExitUsage
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		return help(ctx, args[1:])
+	}
+	command, ok := registry[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		displayHelp(os.Stderr)
+		return ExitUsage
+	}
+
+	conf := configuration.ContextConfiguration(ctx)
+	fs, options := command.flagSet(conf)
+	err := fs.Parse(args[1:])
+	if err == flag.ErrHelp {
+		return ExitOK
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	// Flags only apply to this run, so restore the configured values before the configuration is persisted.
+	defer fs.Visit(func(f *flag.Flag) {
+		f.Value.Set(f.DefValue)
+	})
+	if options.dryRun {
+		ctx = plan.ContextWithPlan(ctx)
+	} else if command.Mutates {
+		j := &journal.Journal{RunID: journal.NewRunID(), Command: command.Name}
+		defer j.Close()
+		ctx = journal.ContextWithJournal(ctx, j)
+		log.WithField("run", j.RunID).Info("Recording changes to the journal")
+	}
+
+	// A service already on the context, such as a fake, is used as is.
+	if command.NeedsService && service.ContextService(ctx) == nil {
+		ctx, err = spotifyclient.ContextWithClient(ctx)
+		if err != nil {
+			log.WithError(err).Error("Could not create Spotify client")
+			return ExitFailure
+		}
+		ctx = service.ContextWithService(ctx, service.NewSpotify(spotifyclient.ContextClient(ctx)))
+	}
+	err = command.Run(ctx, fs.Args())
+	if err != nil {
+		log.WithError(err).WithField("command", command.Name).Error("Command failed")
+		return ExitFailure
+	}
+	if p := plan.ContextPlan(ctx); p != nil {
+		if options.format == "json" {
+			err = p.WriteJSON(os.Stdout)
+		} else {
+			err = p.Write(os.Stdout)
+		}
+		if err != nil {
+			log.WithError(err).Error("Could not write plan")
+			return ExitFailure
+		}
+	}
+	return ExitOK
+}
+
+type globalOptions struct {
+	dryRun bool
+	format string
+}
+
+// flagSet returns the flag set for the command, including the shared flags for mutating commands.
+func (command Command) flagSet(conf *configuration.Configuration) (*flag.FlagSet, *globalOptions) {
+	options := &globalOptions{}
+	fs := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		command.displayUsage(fs.Output(), fs)
+	}
+	if command.Mutates {
+		fs.BoolVar(&options.dryRun, "dry-run", false, "print a plan of changes instead of making them")
+		fs.StringVar(&options.format, "format", "text", "plan output format when using -dry-run: text or json")
+	}
+	if command.Flags != nil {
+		command.Flags(fs, conf)
+	}
+	return fs, options
+}
+
+func (command Command) displayUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: musicmanager %s [flags] %s\n\n%s\n", command.Name, command.Usage, command.Description)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) {
+		hasFlags = true
+	})
+	if !hasFlags {
+		return
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// help displays the list of commands, or the usage of a single command.
+func help(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		displayHelp(os.Stdout)
+		return ExitOK
+	}
+	command, ok := registry[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		displayHelp(os.Stderr)
+		return ExitUsage
+	}
+	fs, _ := command.flagSet(configuration.ContextConfiguration(ctx))
+	command.displayUsage(os.Stdout, fs)
+	return ExitOK
+}
+
+func displayHelp(w io.Writer) {
+	fmt.Fprintf(w, "Usage: musicmanager <command> [flags] [args]\n\nCommands:\n")
+	for _, command := range All() {
+		fmt.Fprintf(w, "  %-26s %s\n", command.Name, command.Description)
+	}
+	fmt.Fprintf(w, "\nRun \"musicmanager help <command>\" for the flags of a command.\n")
+}
@@ -2,34 +2,50 @@ package commands
 
 import (
 	"context"
+	"flag"
 
 	"github.com/snikch/api/log"
 	"github.com/snikch/musicmanager/configuration"
-	"github.com/snikch/musicmanager/itunes"
 	"github.com/snikch/musicmanager/music"
 	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
 )
 
+func init() {
+	Register(Command{
+		Name:         "remove-unwanted",
+		Description:  "Remove files with the delete tag from Spotify playlists, iTunes and disk",
+		Mutates:      true,
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.StringVar(&conf.MusicFiles.DeleteTag, "delete-tag", conf.MusicFiles.DeleteTag, "genre tag marking files to remove (default delete)")
+			fs.StringVar(&conf.ITunes.Dir, "itunes-dir", conf.ITunes.Dir, "directory containing the iTunes Music Library.xml")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return RemoveUnwanted(ctx)
+		},
+	})
+}
+
 // RemoveUnwanted removes all files with the delete tag from iTunes and any Spotify playlists.
 func RemoveUnwanted(ctx context.Context) error {
-	graph, err := spotify.GetTrackGraph(ctx)
+	graph, contexts, err := loadFileContexts(ctx)
 	if err != nil {
 		return err
 	}
-	files, err := music.GetAllFiles(ctx)
+	local := make([]types.RecordingKey, 0, len(contexts))
+	for key := range contexts {
+		local = append(local, key.Recording())
+	}
+	ctx, store, err := withStateStore(ctx, local)
 	if err != nil {
 		return err
 	}
-	loc := configuration.ContextConfiguration(ctx).ITunes.Dir + "iTunes Music Library.xml"
-	library, err := itunes.LoadLibrary(loc)
+	didRemove, err := music.RemoveUnwanted(ctx, graph, contexts)
 	if err != nil {
 		return err
 	}
-	contexts := music.FilesToFileContexts(ctx, files)
-	contexts = music.HydrateSpotifyOnContexts(ctx, contexts, graph)
-	contexts = music.HydrateITunesOnContexts(ctx, contexts, library)
-
-	didRemove, err := music.RemoveUnwanted(ctx, graph, contexts)
+	err = saveStateStore(ctx, store)
 	if err != nil {
 		return err
 	}
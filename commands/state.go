@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/state"
+	"github.com/snikch/musicmanager/types"
+)
+
+func init() {
+	Register(Command{
+		Name:        "state",
+		Description: "Show how many recordings are in each state, or set the state of a recording",
+		Usage:       "[have|want|dont-want|undecided \"Artist - Title (Mix)\"]",
+		Mutates:     true,
+		Run: func(ctx context.Context, args []string) error {
+			switch len(args) {
+			case 0:
+				return ShowStates(ctx)
+			case 2:
+				to, err := state.ParseState(args[0])
+				if err != nil {
+					return err
+				}
+				key, err := parseRecordingKey(args[1])
+				if err != nil {
+					return err
+				}
+				return SetState(ctx, key, to)
+			default:
+				return errors.New("state accepts either no arguments, or a state and a recording")
+			}
+		},
+	})
+}
+
+// ShowStates prints the number of recordings in each state.
+func ShowStates(ctx context.Context) error {
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, store, err := withStateStore(ctx, music.LocalRecordings(files))
+	if err != nil {
+		return err
+	}
+	err = saveStateStore(ctx, store)
+	if err != nil {
+		return err
+	}
+	return writeStates(os.Stdout, store.Counts())
+}
+
+// SetState sets the state of a single recording.
+func SetState(ctx context.Context, key types.RecordingKey, to state.State) error {
+	ctx, store, err := withStateStore(ctx, nil)
+	if err != nil {
+		return err
+	}
+	music.SetRecordingState(ctx, key, to)
+	return saveStateStore(ctx, store)
+}
+
+func writeStates(w io.Writer, counts map[state.State]int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, s := range state.States {
+		if s == state.Undecided {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\n", s, counts[s])
+	}
+	return tw.Flush()
+}
+
+// parseRecordingKey parses a recording written as "Artist - Title (Mix)".
+func parseRecordingKey(recording string) (types.RecordingKey, error) {
+	parts := strings.SplitN(recording, " - ", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return types.RecordingKey{}, fmt.Errorf("expected a recording written as \"Artist - Title (Mix)\", got %q", recording)
+	}
+	return types.RecordingKeyForTitle(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])), nil
+}
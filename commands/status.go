@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/spotify"
+)
+
+// statusVerbose is set by the -v flag of status.
+var statusVerbose bool
+
+func init() {
+	Register(Command{
+		Name:        "status",
+		Description: "Summarise how local files, Spotify playlists and iTunes line up",
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.BoolVar(&statusVerbose, "v", false, "list every file and track in each section, not just counts")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return Status(ctx)
+		},
+	})
+}
+
+// Status prints a coverage report of the local library against Spotify and iTunes.
+func Status(ctx context.Context) error {
+	graph, err := spotify.GetTrackGraph(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := music.GetAllFiles(ctx)
+	if err != nil {
+		return err
+	}
+	library, err := loadITunesLibrary(ctx)
+	if err != nil {
+		return err
+	}
+	status := music.BuildStatus(ctx, files, graph, library)
+	return writeStatus(os.Stdout, status, statusVerbose)
+}
+
+func writeStatus(w io.Writer, status music.Status, verbose bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Local files\t%d\n", status.Files)
+	fmt.Fprintf(tw, "Spotify playlist tracks\t%d\n", status.SpotifyTracks)
+	fmt.Fprintf(tw, "iTunes tracks\t%d\n", status.ITunesTracks)
+	fmt.Fprintf(tw, "Files with no artist or title (skipped)\t%d\n", len(status.UnknownFiles))
+	fmt.Fprintf(tw, "Files with no Spotify match\t%d\n", len(status.NoSpotifyFiles))
+	fmt.Fprintf(tw, "  of which near misses\t%d\n", len(status.NearMisses))
+	fmt.Fprintf(tw, "Files missing from iTunes\t%d\n", len(status.NoITunesFiles))
+	fmt.Fprintf(tw, "Spotify tracks with no local file\t%d\n", len(status.MissingLocal))
+	fmt.Fprintf(tw, "iTunes tracks with no file on disk\t%d\n", len(status.DeadITunes))
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nPlaylists\n")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTRACKS\tLOCAL\tMISSING")
+	for _, playlist := range status.Playlists {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", playlist.Name, playlist.Tracks, playlist.Local, playlist.Missing)
+	}
+	err = tw.Flush()
+	if err != nil || !verbose {
+		return err
+	}
+
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s\n", title)
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	lines := []string{}
+	for _, file := range status.UnknownFiles {
+		lines = append(lines, file.Path())
+	}
+	section("Files with no artist or title", lines)
+	lines = []string{}
+	for _, file := range status.NoSpotifyFiles {
+		lines = append(lines, file.Path())
+	}
+	section("Files with no Spotify match", lines)
+	lines = []string{}
+	for _, miss := range status.NearMisses {
+		lines = append(lines, fmt.Sprintf("%.2f  %s  ~  %s - %s", miss.Score, miss.File.Path(), miss.Track.Artist, miss.Track.Title))
+	}
+	section("Near misses (score, file, closest Spotify track)", lines)
+	lines = []string{}
+	for _, file := range status.NoITunesFiles {
+		lines = append(lines, file.Path())
+	}
+	section("Files missing from iTunes", lines)
+	lines = []string{}
+	for _, key := range status.MissingLocal {
+		lines = append(lines, key.Artist+" - "+key.Title)
+	}
+	section("Spotify tracks with no local file", lines)
+	lines = []string{}
+	for _, track := range status.DeadITunes {
+		path, _ := track.Path()
+		lines = append(lines, path)
+	}
+	section("iTunes tracks with no file on disk", lines)
+	return nil
+}
@@ -2,30 +2,32 @@ package commands
 
 import (
 	"context"
+	"flag"
 
 	"github.com/snikch/musicmanager/configuration"
-	"github.com/snikch/musicmanager/itunes"
-
 	"github.com/snikch/musicmanager/music"
-	"github.com/snikch/musicmanager/spotify"
 )
 
+func init() {
+	Register(Command{
+		Name:         "tag-files",
+		Description:  "Update Genre, Year and Comment tags of local files from Spotify playlists and iTunes",
+		Mutates:      true,
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.StringVar(&conf.ITunes.Dir, "itunes-dir", conf.ITunes.Dir, "directory containing the iTunes Music Library.xml")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return TagFiles(ctx)
+		},
+	})
+}
+
+// TagFiles updates the tags of all local files based on their Spotify playlists and iTunes rating.
 func TagFiles(ctx context.Context) error {
-	graph, err := spotify.GetTrackGraph(ctx)
-	if err != nil {
-		return err
-	}
-	files, err := music.GetAllFiles(ctx)
-	if err != nil {
-		return err
-	}
-	loc := configuration.ContextConfiguration(ctx).ITunes.Dir + "iTunes Music Library.xml"
-	library, err := itunes.LoadLibrary(loc)
+	_, contexts, err := loadFileContexts(ctx)
 	if err != nil {
 		return err
 	}
-	contexts := music.FilesToFileContexts(ctx, files)
-	contexts = music.HydrateSpotifyOnContexts(ctx, contexts, graph)
-	contexts = music.HydrateITunesOnContexts(ctx, contexts, library)
 	return music.UpdateFilesTags(ctx, contexts)
 }
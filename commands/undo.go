@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/music"
+)
+
+// undoList is set by the -list flag of undo.
+var undoList bool
+
+func init() {
+	Register(Command{
+		Name:         "undo",
+		Description:  "Reverse the tag writes and playlist changes of a previous run",
+		NeedsService: true,
+		Usage:        "[run-id]",
+		Mutates:      true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.BoolVar(&undoList, "list", false, "list the runs in the journal instead of undoing one")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			if len(args) > 1 {
+				return errors.New("undo accepts a single run id")
+			}
+			runID := ""
+			if len(args) == 1 {
+				runID = args[0]
+			}
+			return Undo(ctx, runID, undoList)
+		},
+	})
+}
+
+// Undo reverses the journaled changes of the supplied run, or the most recent run that hasn't been undone.
+func Undo(ctx context.Context, runID string, list bool) error {
+	entries, err := journal.Load()
+	if err != nil {
+		return err
+	}
+	if list {
+		return writeRuns(os.Stdout, entries)
+	}
+	if runID == "" {
+		runID = journal.LastRun(entries)
+	}
+	if runID == "" {
+		return errors.New("there are no runs in the journal to undo")
+	}
+	run := journal.RunEntries(entries, runID)
+	if len(run) == 0 {
+		return fmt.Errorf("run %q is not in the journal", runID)
+	}
+	if j := journal.ContextJournal(ctx); j != nil {
+		j.Undoes = runID
+	}
+	log.WithField("run", runID).
+		WithField("command", run[0].Command).
+		WithField("changes", len(run)).
+		Info("Undoing run")
+	ctx, store, err := withStateStore(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = music.Undo(ctx, run)
+	if err != nil {
+		return err
+	}
+	return saveStateStore(ctx, store)
+}
+
+// writeRuns lists every run in the journal with the number of changes it made, in the order the runs started. Runs
+// at the same time write their entries interleaved, so entries are counted by run rather than in sequence.
+func writeRuns(w io.Writer, entries []journal.Entry) error {
+	runs := []journal.Entry{}
+	counts := map[string]int{}
+	for _, entry := range entries {
+		if counts[entry.RunID] == 0 {
+			runs = append(runs, entry)
+		}
+		counts[entry.RunID]++
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tCOMMAND\tCHANGES\tUNDOES")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", run.RunID, run.Command, counts[run.RunID], run.Undoes)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+)
+
+// verifyTolerance is set by the -tolerance flag of verify.
+var verifyTolerance time.Duration
+
+func init() {
+	Register(Command{
+		Name:        "verify",
+		Description: "Find local files whose length differs from the Spotify track they match, e.g. a radio edit instead of the extended mix",
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.DurationVar(&verifyTolerance, "tolerance", music.DefaultVerifyTolerance, "how different the lengths can be and still be the same version")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return Verify(ctx, verifyTolerance)
+		},
+	})
+}
+
+// Verify reports every local file that is shorter or longer than the Spotify track it matches.
+func Verify(ctx context.Context, tolerance time.Duration) error {
+	_, contexts, err := loadFileContexts(ctx)
+	if err != nil {
+		return err
+	}
+	mismatches, skipped := music.VerifyDurations(contexts, tolerance)
+	return writeVerify(os.Stdout, mismatches, skipped)
+}
+
+func writeVerify(w io.Writer, mismatches []music.DurationMismatch, skipped int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOCAL IS\tLOCAL\tSPOTIFY\tDIFFERENCE\tFILE\tSPOTIFY TRACK")
+	for _, mismatch := range mismatches {
+		result := "longer"
+		if mismatch.Shorter() {
+			result = "shorter"
+		}
+		difference := mismatch.Difference()
+		sign := "+"
+		if difference < 0 {
+			sign = "-"
+			difference = -difference
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s%s\t%s\t%s - %s\n",
+			result,
+			formatLength(mismatch.Local),
+			formatLength(mismatch.Spotify),
+			sign,
+			formatLength(difference),
+			mismatch.File.Path(),
+			mismatch.Track.Artist,
+			mismatch.Track.Title,
+		)
+	}
+	fmt.Fprintf(tw, "\n%d files differ from their Spotify track\n", len(mismatches))
+	if skipped > 0 {
+		fmt.Fprintf(tw, "%d matched files were skipped as their length couldn't be read\n", skipped)
+	}
+	return tw.Flush()
+}
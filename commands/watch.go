@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/music"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+)
+
+// watchDebounce is set by the -debounce flag of watch.
+var watchDebounce time.Duration
+
+func init() {
+	Register(Command{
+		Name:         "watch",
+		Description:  "Watch the music directories and tag new and changed files as they appear",
+		Mutates:      true,
+		NeedsService: true,
+		Flags: func(fs *flag.FlagSet, conf *configuration.Configuration) {
+			fs.DurationVar(&watchDebounce, "debounce", 5*time.Second, "how long a file must be unchanged before it's tagged")
+		},
+		Run: func(ctx context.Context, args []string) error {
+			return Watch(ctx, watchDebounce)
+		},
+	})
+}
+
+// Watch tags files dropped into the music directories until interrupted.
+func Watch(ctx context.Context, debounce time.Duration) error {
+	graph, err := spotify.GetTrackGraph(ctx)
+	if err != nil {
+		return err
+	}
+	// Linking needs every file, so new files are linked against the catalog saved by link-songs.
+	catalog, err := types.LoadCatalog(music.CatalogLoc)
+	if err != nil {
+		return fail.Trace(err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	loc := configuration.ContextConfiguration(ctx).ITunes.Dir + "iTunes Music Library.xml"
+	return music.Watch(ctx, graph, catalog, loc, debounce)
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/music"
+)
+
+func init() {
+	Register(Command{
+		Name:        "write-isrcs",
+		Description: "Write the ISRC of matched Spotify tracks into local files that don't have one",
+		Mutates:     true,
+		Run: func(ctx context.Context, args []string) error {
+			return WriteISRCs(ctx)
+		},
+	})
+}
+
+// WriteISRCs sets the ISRC tag of every local file missing one from the Spotify track it matches.
+func WriteISRCs(ctx context.Context) error {
+	_, contexts, err := loadFileContexts(ctx)
+	if err != nil {
+		return err
+	}
+	written, err := music.WriteMissingISRCs(ctx, contexts)
+	if err != nil {
+		return err
+	}
+	log.WithField("files", written).Info("Wrote missing ISRCs")
+	return nil
+}
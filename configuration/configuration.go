@@ -8,13 +8,24 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// Playlist identifies a Spotify playlist managed by musicmanager. The ID is saved once the playlist is created.
+type Playlist struct {
+	ID   string
+	Name string
+}
+
 type Configuration struct {
 	Spotify struct {
-		AuthToken      *oauth2.Token
-		PlaylistRegex  string
-		OutputPlaylist struct {
+		AuthToken         *oauth2.Token
+		PlaylistRegex     string
+		OutputPlaylist    Playlist
+		LongerPlaylist    Playlist
+		FollowingPlaylist struct {
 			ID   string
 			Name string
+			// ReleasedAfter is the watermark date (YYYY-MM-DD) of the last run. Releases from that date on are checked
+			// on the next run.
+			ReleasedAfter string
 		}
 	}
 	ITunes struct {
@@ -22,6 +33,7 @@ type Configuration struct {
 		Artists struct {
 			SpotifyOverrides map[string]string
 			Skip             []string
+			MinRating        int
 		}
 	}
 	MusicFiles struct {
@@ -30,6 +42,27 @@ type Configuration struct {
 		DeleteTag       string
 		CommentRemovals []string
 		Dirs            []string
+		// ImportDir is where imported files are moved to, defaulting to the first of Dirs.
+		ImportDir string
+		// RejectsDir is where imported duplicates are moved to.
+		RejectsDir string
+		// SongPlaylistTags tags files with the playlists of every recording of their song, as linked by link-songs,
+		// rather than only the playlists of the file's own recording.
+		SongPlaylistTags bool
+		// Workers is how many files are parsed at once, defaulting to the number of CPUs.
+		Workers int
+		// LowBitrate tags MP3 files whose average bitrate is below Threshold kbps, so they can be replaced. Files
+		// aren't tagged unless a threshold is set.
+		LowBitrate struct {
+			Tag       string
+			Threshold int
+		}
+		// Organize is where the organize command moves files to. Template is the path of each file relative to Dir,
+		// which defaults to ImportDir or the first of Dirs.
+		Organize struct {
+			Dir      string
+			Template string
+		}
 	}
 }
 
@@ -0,0 +1,326 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Metadata block types.
+const (
+	blockStreamInfo    = 0
+	blockPadding       = 1
+	blockVorbisComment = 4
+)
+
+// maxBlockLength is the largest metadata block, as the length is a 24 bit integer.
+const maxBlockLength = 1<<24 - 1
+
+var (
+	// ErrNotFLAC is returned when a file doesn't start with the FLAC stream marker.
+	ErrNotFLAC = errors.New("flac: not a FLAC file")
+	// ErrInvalidComment is returned when the Vorbis comment block can't be parsed.
+	ErrInvalidComment = errors.New("flac: invalid vorbis comment block")
+)
+
+// block is a single metadata block.
+type block struct {
+	kind byte
+	data []byte
+}
+
+// File is the Vorbis comment metadata of a FLAC file. Changes are only written to disk by Save.
+type File struct {
+	path string
+	// prefix is an ID3v2 tag some taggers write before the stream marker, which is kept as is.
+	prefix []byte
+	blocks []block
+	// audioOffset is the position of the first audio frame, after the metadata blocks.
+	audioOffset int64
+	vendor      string
+	// comments are the "NAME=value" fields of the Vorbis comment block, in file order.
+	comments []string
+}
+
+// Open reads the metadata blocks of the FLAC file at the supplied path.
+func Open(path string) (*File, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	file := &File{path: path}
+	err = file.read(source)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (file *File) read(r io.Reader) error {
+	marker := make([]byte, 10)
+	_, err := io.ReadFull(r, marker[:4])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrNotFLAC
+	}
+	if err != nil {
+		return err
+	}
+	offset := int64(4)
+	if string(marker[:3]) == "ID3" {
+		_, err = io.ReadFull(r, marker[4:])
+		if err != nil {
+			return ErrNotFLAC
+		}
+		// The tag size is a syncsafe integer, excluding the header.
+		size := int64(marker[6])<<21 | int64(marker[7])<<14 | int64(marker[8])<<7 | int64(marker[9])
+		file.prefix = make([]byte, 10+size)
+		copy(file.prefix, marker)
+		_, err = io.ReadFull(r, file.prefix[10:])
+		if err != nil {
+			return ErrNotFLAC
+		}
+		_, err = io.ReadFull(r, marker[:4])
+		if err != nil {
+			return ErrNotFLAC
+		}
+		offset += int64(len(file.prefix))
+	}
+	if string(marker[:4]) != "fLaC" {
+		return ErrNotFLAC
+	}
+	header := make([]byte, 4)
+	for last := false; !last; {
+		_, err := io.ReadFull(r, header)
+		if err != nil {
+			return ErrNotFLAC
+		}
+		last = header[0]&0x80 != 0
+		kind := header[0] & 0x7F
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		data := make([]byte, length)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return ErrNotFLAC
+		}
+		offset += int64(4 + length)
+		if kind == blockVorbisComment {
+			err = file.parseComments(data)
+			if err != nil {
+				return err
+			}
+		}
+		file.blocks = append(file.blocks, block{kind: kind, data: data})
+	}
+	if len(file.blocks) == 0 || file.blocks[0].kind != blockStreamInfo {
+		return ErrNotFLAC
+	}
+	file.audioOffset = offset
+	return nil
+}
+
+// Duration returns the length of the audio from the stream info block, or 0 if the encoder didn't record it.
+func (file *File) Duration() time.Duration {
+	info := file.blocks[0].data
+	if len(info) < 18 {
+		return 0
+	}
+	// After the block and frame sizes are a 20 bit sample rate, 3 bit channels, 5 bit sample size and 36 bit total
+	// samples.
+	rate := uint64(info[10])<<12 | uint64(info[11])<<4 | uint64(info[12])>>4
+	samples := uint64(info[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(info[14:]))
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(rate) * float64(time.Second))
+}
+
+// parseComments reads a Vorbis comment block, whose lengths are little endian unlike the rest of the file.
+func (file *File) parseComments(data []byte) error {
+	next := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		length := binary.LittleEndian.Uint32(data)
+		if uint64(length) > uint64(len(data)-4) {
+			return "", false
+		}
+		value := string(data[4 : 4+length])
+		data = data[4+length:]
+		return value, true
+	}
+	vendor, ok := next()
+	if !ok || len(data) < 4 {
+		return ErrInvalidComment
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	file.vendor = vendor
+	file.comments = nil
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return ErrInvalidComment
+		}
+		file.comments = append(file.comments, comment)
+	}
+	return nil
+}
+
+// commentBlock returns the Vorbis comment block for the current comments.
+func (file *File) commentBlock() []byte {
+	out := &bytes.Buffer{}
+	binary.Write(out, binary.LittleEndian, uint32(len(file.vendor)))
+	out.WriteString(file.vendor)
+	binary.Write(out, binary.LittleEndian, uint32(len(file.comments)))
+	for _, comment := range file.comments {
+		binary.Write(out, binary.LittleEndian, uint32(len(comment)))
+		out.WriteString(comment)
+	}
+	return out.Bytes()
+}
+
+// fieldName returns the field name of a comment, upper cased as field names aren't case sensitive.
+func fieldName(comment string) string {
+	i := strings.IndexByte(comment, '=')
+	if i < 0 {
+		return ""
+	}
+	return strings.ToUpper(comment[:i])
+}
+
+// Values returns every value of a field, such as "GENRE".
+func (file *File) Values(name string) []string {
+	name = strings.ToUpper(name)
+	values := []string{}
+	for _, comment := range file.comments {
+		if fieldName(comment) == name {
+			values = append(values, comment[len(name)+1:])
+		}
+	}
+	return values
+}
+
+// Get returns the first value of a field, or an empty string if it isn't set.
+func (file *File) Get(name string) string {
+	values := file.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set replaces every value of a field with the supplied value, removing the field if the value is empty.
+func (file *File) Set(name, value string) {
+	name = strings.ToUpper(name)
+	comments := file.comments[:0]
+	for _, comment := range file.comments {
+		if fieldName(comment) != name {
+			comments = append(comments, comment)
+		}
+	}
+	file.comments = comments
+	if value != "" {
+		file.comments = append(file.comments, name+"="+value)
+	}
+}
+
+// Save writes the comments back to the file. The file is rewritten next to the original and then moved over it, so
+// it's never left half written. Any padding block is resized to make up for the new comments where possible, so the
+// audio stays in place.
+func (file *File) Save() error {
+	comments := file.commentBlock()
+	if len(comments) > maxBlockLength {
+		return errors.New("flac: vorbis comments are too long")
+	}
+	blocks := make([]block, 0, len(file.blocks)+1)
+	delta := len(comments)
+	found := false
+	for _, b := range file.blocks {
+		if b.kind == blockVorbisComment {
+			if found {
+				// There should only be one comment block, so drop any others.
+				delta -= 4 + len(b.data)
+				continue
+			}
+			found = true
+			delta -= len(b.data)
+			b.data = comments
+		}
+		blocks = append(blocks, b)
+	}
+	if !found {
+		delta += 4
+		// The comments go straight after the stream info, which must be first.
+		blocks = append(blocks[:1], append([]block{{kind: blockVorbisComment, data: comments}}, blocks[1:]...)...)
+	}
+	for i := range blocks {
+		if blocks[i].kind == blockPadding && len(blocks[i].data)-delta >= 0 {
+			blocks[i].data = make([]byte, len(blocks[i].data)-delta)
+			break
+		}
+	}
+
+	source, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	stat, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(file.path), "."+filepath.Base(file.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	err = writeFile(temp, file.prefix, blocks, io.NewSectionReader(source, file.audioOffset, stat.Size()-file.audioOffset))
+	if err == nil {
+		err = temp.Chmod(stat.Mode())
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(temp.Name(), file.path)
+	if err != nil {
+		return err
+	}
+	file.blocks = blocks
+	file.audioOffset = int64(len(file.prefix)) + 4
+	for _, b := range blocks {
+		file.audioOffset += int64(4 + len(b.data))
+	}
+	return nil
+}
+
+// writeFile writes the stream marker, metadata blocks and audio.
+func writeFile(dest io.Writer, prefix []byte, blocks []block, audio io.Reader) error {
+	out := &bytes.Buffer{}
+	out.Write(prefix)
+	out.WriteString("fLaC")
+	for i, b := range blocks {
+		kind := b.kind
+		if i == len(blocks)-1 {
+			kind |= 0x80
+		}
+		length := len(b.data)
+		out.Write([]byte{kind, byte(length >> 16), byte(length >> 8), byte(length)})
+		out.Write(b.data)
+	}
+	_, err := dest.Write(out.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dest, audio)
+	return err
+}
@@ -0,0 +1,189 @@
+package flac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testAudio = []byte("AUDIO FRAMES")
+
+func testBlock(kind byte, last bool, data []byte) []byte {
+	if last {
+		kind |= 0x80
+	}
+	length := len(data)
+	return append([]byte{kind, byte(length >> 16), byte(length >> 8), byte(length)}, data...)
+}
+
+func testComments(vendor string, comments ...string) []byte {
+	out := &bytes.Buffer{}
+	binary.Write(out, binary.LittleEndian, uint32(len(vendor)))
+	out.WriteString(vendor)
+	binary.Write(out, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(out, binary.LittleEndian, uint32(len(comment)))
+		out.WriteString(comment)
+	}
+	return out.Bytes()
+}
+
+// testFile writes a FLAC file with the supplied prefix and blocks after the stream info, returning its path.
+func testFile(t *testing.T, prefix []byte, blocks ...[]byte) string {
+	contents := append(append([]byte{}, prefix...), "fLaC"...)
+	contents = append(contents, testBlock(blockStreamInfo, len(blocks) == 0, make([]byte, 34))...)
+	for _, b := range blocks {
+		contents = append(contents, b...)
+	}
+	contents = append(contents, testAudio...)
+	path := filepath.Join(t.TempDir(), "song.flac")
+	err := ioutil.WriteFile(path, contents, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkAudio(t *testing.T, path string) []byte {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(contents, testAudio) {
+		t.Fatal("Expected the audio to be kept")
+	}
+	return contents
+}
+
+func TestReadComments(t *testing.T) {
+	path := testFile(t, nil,
+		testBlock(blockVorbisComment, false, testComments("reference", "artist=Someone", "TITLE=Song", "GENRE=house", "Genre=vocal")),
+		testBlock(blockPadding, true, make([]byte, 16)),
+	)
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Get("ARTIST") != "Someone" || file.Get("title") != "Song" || file.Get("DATE") != "" {
+		t.Fatalf("Expected fields to be found ignoring case, got %q and %q", file.Get("ARTIST"), file.Get("title"))
+	}
+	genres := file.Values("GENRE")
+	if len(genres) != 2 || genres[0] != "house" || genres[1] != "vocal" {
+		t.Fatalf("Expected both genres, got %v", genres)
+	}
+}
+
+func TestSaveComments(t *testing.T) {
+	id3 := append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 6}, "ID3TAG"...)
+	for name, blocks := range map[string][][]byte{
+		"padded": {
+			testBlock(blockVorbisComment, false, testComments("reference", "TITLE=Song", "GENRE=house")),
+			testBlock(blockPadding, true, make([]byte, 256)),
+		},
+		"unpadded": {
+			testBlock(blockVorbisComment, true, testComments("reference", "TITLE=Song", "GENRE=house")),
+		},
+		"no comments": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := testFile(t, id3, blocks...)
+			before := checkAudio(t, path)
+			file, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Set("GENRE", "house vocal")
+			file.Set("date", "2017")
+			file.Set("COMMENT", "Rating: 4")
+			err = file.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := checkAudio(t, path)
+			if !bytes.HasPrefix(after, id3) {
+				t.Fatal("Expected the ID3 tag to be kept")
+			}
+			if name == "padded" && len(after) != len(before) {
+				t.Fatalf("Expected the padding to be used, but the file went from %d to %d bytes", len(before), len(after))
+			}
+			file, err = Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Get("GENRE") != "house vocal" || len(file.Values("GENRE")) != 1 {
+				t.Fatalf("Expected a single new genre, got %v", file.Values("GENRE"))
+			}
+			if file.Get("DATE") != "2017" || file.Get("COMMENT") != "Rating: 4" {
+				t.Fatalf("Expected the date and comment, got %q and %q", file.Get("DATE"), file.Get("COMMENT"))
+			}
+			if len(blocks) > 0 && (file.Get("TITLE") != "Song" || file.vendor != "reference") {
+				t.Fatal("Expected the other fields and vendor to be kept")
+			}
+
+			file.Set("COMMENT", "")
+			err = file.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkAudio(t, path)
+			file, err = Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Get("COMMENT") != "" || file.Get("GENRE") != "house vocal" {
+				t.Fatal("Expected only the comment to be removed")
+			}
+		})
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.flac")
+	for _, contents := range [][]byte{
+		[]byte("RIFF"),
+		[]byte("fLaC"),
+		append([]byte("fLaC"), testBlock(blockPadding, true, nil)...),
+		append([]byte("fLaC"), testBlock(blockVorbisComment, true, []byte{9, 0, 0, 0})...),
+	} {
+		err := ioutil.WriteFile(path, contents, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open(path); err == nil {
+			t.Fatalf("Expected %q to be invalid", contents)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	// 90 seconds of 44.1kHz stereo 16 bit audio.
+	rate, samples := 44100, uint64(44100*90)
+	info := make([]byte, 34)
+	info[10] = byte(rate >> 12)
+	info[11] = byte(rate >> 4)
+	info[12] = byte(rate<<4) | 1<<1
+	info[13] = 15<<4 | byte(samples>>32)
+	binary.BigEndian.PutUint32(info[14:], uint32(samples))
+	path := filepath.Join(t.TempDir(), "song.flac")
+	err := ioutil.WriteFile(path, append(append([]byte("fLaC"), testBlock(blockStreamInfo, true, info)...), testAudio...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Duration() != 90*time.Second {
+		t.Fatalf("Expected 1m30s, got %v", file.Duration())
+	}
+	file, err = Open(testFile(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Duration() != 0 {
+		t.Fatalf("Expected no duration without a sample rate, got %v", file.Duration())
+	}
+}
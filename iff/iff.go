@@ -0,0 +1,250 @@
+// Package iff reads and writes the chunks of AIFF (big endian "FORM") and WAV (little endian "RIFF") files, which
+// keep ID3 tags in an "ID3 " chunk.
+package iff
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// headerLength is the length of the container header: the "FORM" or "RIFF" ID, size and form type.
+const headerLength = 12
+
+// ErrNotIFF is returned when a file isn't an AIFF or WAV file, or its chunks run past the end of the file.
+var ErrNotIFF = errors.New("iff: not an AIFF or WAV file")
+
+// chunk is the position of a chunk's payload in the file.
+type chunk struct {
+	id     string
+	offset int64
+	size   int64
+}
+
+// File is the chunk layout of an AIFF or WAV file. Changes are only written to disk by Save.
+type File struct {
+	path  string
+	order binary.ByteOrder
+	// id is "FORM" or "RIFF", and form the form type such as "AIFF", "AIFC" or "WAVE".
+	id     string
+	form   string
+	chunks []chunk
+	// format is the payload of the AIFF COMM or WAV fmt chunk, which describes the audio.
+	format []byte
+	// id3 is the payload of the ID3 chunk, or nil if there isn't one.
+	id3     []byte
+	changed bool
+}
+
+// Open reads the chunks of the AIFF or WAV file at the supplied path, and the ID3 chunk if there is one.
+func Open(path string) (*File, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	stat, err := source.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerLength)
+	_, err = io.ReadFull(source, header)
+	if err != nil {
+		return nil, ErrNotIFF
+	}
+	file := &File{path: path, id: string(header[:4]), form: string(header[8:])}
+	switch file.id {
+	case "FORM":
+		file.order = binary.BigEndian
+	case "RIFF":
+		file.order = binary.LittleEndian
+	default:
+		return nil, ErrNotIFF
+	}
+	// Trust the file size over the container size, which some encoders get wrong.
+	for offset := int64(headerLength); offset+8 <= stat.Size(); {
+		_, err = source.ReadAt(header[:8], offset)
+		if err != nil {
+			return nil, err
+		}
+		c := chunk{id: string(header[:4]), offset: offset + 8, size: int64(file.order.Uint32(header[4:]))}
+		if c.offset+c.size > stat.Size() {
+			return nil, ErrNotIFF
+		}
+		if (c.id == "COMM" || c.id == "fmt ") && file.format == nil {
+			file.format = make([]byte, c.size)
+			_, err = source.ReadAt(file.format, c.offset)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if isID3(c.id) && file.id3 == nil {
+			file.id3 = make([]byte, c.size)
+			_, err = source.ReadAt(file.id3, c.offset)
+			if err != nil {
+				return nil, err
+			}
+		}
+		file.chunks = append(file.chunks, c)
+		// Chunks are padded to an even length.
+		offset = c.offset + c.size + c.size%2
+	}
+	return file, nil
+}
+
+// isID3 returns true for the ID of an ID3 chunk, which is "ID3 " in AIFF files and usually "id3 " in WAV files.
+func isID3(id string) bool {
+	return strings.EqualFold(id, "ID3 ")
+}
+
+// Duration returns the length of the audio, or 0 if it can't be worked out. AIFF files record the number of sample
+// frames and the sample rate in the COMM chunk, while WAV files record the bytes per second in the fmt chunk, which
+// the length of the data chunk is divided by.
+func (file *File) Duration() time.Duration {
+	var seconds float64
+	switch {
+	case file.id == "FORM" && len(file.format) >= 18:
+		frames := file.order.Uint32(file.format[2:])
+		// The sample rate is an 80 bit extended precision float: a sign and 15 bit exponent, then a 64 bit mantissa.
+		exponent := int(file.order.Uint16(file.format[8:]) & 0x7FFF)
+		rate := math.Ldexp(float64(file.order.Uint64(file.format[10:])), exponent-16383-63)
+		if rate > 0 {
+			seconds = float64(frames) / rate
+		}
+	case file.id == "RIFF" && len(file.format) >= 12:
+		rate := file.order.Uint32(file.format[8:])
+		for _, c := range file.chunks {
+			if c.id == "data" && rate > 0 {
+				seconds = float64(c.size) / float64(rate)
+				break
+			}
+		}
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// ID3 returns the ID3 tag, or nil if the file doesn't have one.
+func (file *File) ID3() []byte {
+	return file.id3
+}
+
+// SetID3 replaces the ID3 tag, which is added as a new chunk at the end of the file if there isn't one already.
+func (file *File) SetID3(tag []byte) {
+	file.id3 = tag
+	file.changed = true
+}
+
+// Save writes the ID3 chunk back to the file, updating the container size. The file is rewritten next to the
+// original and then moved over it, so it's never left half written.
+func (file *File) Save() error {
+	if !file.changed {
+		return nil
+	}
+	source, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	stat, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	chunks := []chunk{}
+	found := false
+	for _, c := range file.chunks {
+		if isID3(c.id) {
+			if found {
+				continue
+			}
+			found = true
+		}
+		chunks = append(chunks, c)
+	}
+	if !found && file.id3 != nil {
+		id := "ID3 "
+		if file.id == "RIFF" {
+			id = "id3 "
+		}
+		chunks = append(chunks, chunk{id: id})
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(file.path), "."+filepath.Base(file.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	chunks, err = file.write(temp, source, chunks)
+	if err == nil {
+		err = temp.Chmod(stat.Mode())
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(temp.Name(), file.path)
+	if err != nil {
+		return err
+	}
+	file.chunks = chunks
+	file.changed = false
+	return nil
+}
+
+// write writes the container header and every chunk, taking the ID3 chunk from memory and the rest from the source.
+// It returns the chunks at their new positions.
+func (file *File) write(dest io.Writer, source io.ReaderAt, chunks []chunk) ([]chunk, error) {
+	size := int64(4)
+	for i, c := range chunks {
+		if isID3(c.id) {
+			c.size = int64(len(file.id3))
+			chunks[i] = c
+		}
+		size += 8 + c.size + c.size%2
+	}
+	if size > 0xFFFFFFFF {
+		return nil, errors.New("iff: file is too large")
+	}
+	header := make([]byte, headerLength)
+	copy(header, file.id)
+	file.order.PutUint32(header[4:], uint32(size))
+	copy(header[8:], file.form)
+	_, err := dest.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	offset := int64(headerLength)
+	written := make([]chunk, 0, len(chunks))
+	for _, c := range chunks {
+		copy(header, c.id)
+		file.order.PutUint32(header[4:], uint32(c.size))
+		_, err = dest.Write(header[:8])
+		if err != nil {
+			return nil, err
+		}
+		if isID3(c.id) {
+			_, err = dest.Write(file.id3)
+		} else {
+			_, err = io.Copy(dest, io.NewSectionReader(source, c.offset, c.size))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.size%2 == 1 {
+			_, err = dest.Write([]byte{0})
+			if err != nil {
+				return nil, err
+			}
+		}
+		written = append(written, chunk{id: c.id, offset: offset + 8, size: c.size})
+		offset += 8 + c.size + c.size%2
+	}
+	return written, nil
+}
@@ -0,0 +1,140 @@
+package iff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testChunk returns a chunk with the supplied ID and payload, padded to an even length.
+func testChunk(order binary.ByteOrder, id string, payload []byte) []byte {
+	out := make([]byte, 8, 9+len(payload))
+	copy(out, id)
+	order.PutUint32(out[4:], uint32(len(payload)))
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// testFile writes a container of the supplied chunks, returning its path.
+func testFile(t *testing.T, order binary.ByteOrder, id, form string, chunks ...[]byte) string {
+	body := bytes.Join(chunks, nil)
+	header := make([]byte, headerLength)
+	copy(header, id)
+	order.PutUint32(header[4:], uint32(4+len(body)))
+	copy(header[8:], form)
+	path := filepath.Join(t.TempDir(), "song")
+	err := ioutil.WriteFile(path, append(header, body...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// checkFile fails the test unless the container size matches the file, and returns the file's chunks.
+func checkFile(t *testing.T, path string) *File {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := file.order.Uint32(contents[4:]); int(size) != len(contents)-8 {
+		t.Fatalf("Expected the container size to be %d, got %d", len(contents)-8, size)
+	}
+	return file
+}
+
+func TestID3Chunk(t *testing.T) {
+	for _, test := range []struct {
+		name, id, form, chunkID string
+		order                   binary.ByteOrder
+		existing                bool
+	}{
+		{"aiff", "FORM", "AIFF", "ID3 ", binary.BigEndian, true},
+		{"aiff without tag", "FORM", "AIFF", "ID3 ", binary.BigEndian, false},
+		{"wav", "RIFF", "WAVE", "id3 ", binary.LittleEndian, true},
+		{"wav without tag", "RIFF", "WAVE", "id3 ", binary.LittleEndian, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := [][]byte{
+				testChunk(test.order, "COMM", make([]byte, 18)),
+				testChunk(test.order, "SSND", []byte("AUDIO")),
+			}
+			if test.existing {
+				chunks = append(chunks, testChunk(test.order, test.chunkID, []byte("OLD")))
+			}
+			path := testFile(t, test.order, test.id, test.form, chunks...)
+			file := checkFile(t, path)
+			if test.existing != (string(file.ID3()) == "OLD") {
+				t.Fatalf("Expected the existing tag to be read, got %q", file.ID3())
+			}
+
+			file.SetID3([]byte("NEW TAG"))
+			err := file.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			file = checkFile(t, path)
+			if string(file.ID3()) != "NEW TAG" {
+				t.Fatalf("Expected the new tag, got %q", file.ID3())
+			}
+			ids := []string{}
+			for _, c := range file.chunks {
+				ids = append(ids, c.id)
+			}
+			if len(ids) != 3 || ids[0] != "COMM" || ids[1] != "SSND" || ids[2] != test.chunkID {
+				t.Fatalf("Expected the other chunks to be kept in order and a single tag chunk, got %q", ids)
+			}
+			contents, _ := ioutil.ReadFile(path)
+			if !bytes.Contains(contents, []byte("AUDIO")) {
+				t.Fatal("Expected the audio to be kept")
+			}
+		})
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := testFile(t, binary.BigEndian, "OggS", "AIFF")
+	if _, err := Open(path); err != ErrNotIFF {
+		t.Fatalf("Expected ErrNotIFF, got %v", err)
+	}
+	truncated := testChunk(binary.BigEndian, "SSND", []byte("AUDIO"))
+	path = testFile(t, binary.BigEndian, "FORM", "AIFF", truncated[:10])
+	if _, err := Open(path); err != ErrNotIFF {
+		t.Fatalf("Expected ErrNotIFF for a truncated chunk, got %v", err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	// An AIFF COMM chunk of 60 seconds of 44.1kHz stereo audio, with the rate as an 80 bit float.
+	comm := make([]byte, 18)
+	binary.BigEndian.PutUint16(comm, 2)
+	binary.BigEndian.PutUint32(comm[2:], 44100*60)
+	binary.BigEndian.PutUint16(comm[6:], 16)
+	binary.BigEndian.PutUint16(comm[8:], 16383+15)
+	binary.BigEndian.PutUint64(comm[10:], 44100<<(63-15))
+	aiff := checkFile(t, testFile(t, binary.BigEndian, "FORM", "AIFF", testChunk(binary.BigEndian, "COMM", comm)))
+	if aiff.Duration() != time.Minute {
+		t.Fatalf("Expected the AIFF to be 1m0s, got %v", aiff.Duration())
+	}
+
+	// A WAV fmt chunk of 10 bytes per second, followed by 25 bytes of audio.
+	format := make([]byte, 16)
+	binary.LittleEndian.PutUint16(format, 1)
+	binary.LittleEndian.PutUint32(format[8:], 10)
+	wav := checkFile(t, testFile(t, binary.LittleEndian, "RIFF", "WAVE",
+		testChunk(binary.LittleEndian, "fmt ", format),
+		testChunk(binary.LittleEndian, "data", make([]byte, 25)),
+	))
+	if wav.Duration() != 2500*time.Millisecond {
+		t.Fatalf("Expected the WAV to be 2.5s, got %v", wav.Duration())
+	}
+}
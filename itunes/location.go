@@ -0,0 +1,21 @@
+package itunes
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Path returns the local file path of the track from its file:// Location URL.
+func (track Track) Path() (string, error) {
+	if track.Location == "" {
+		return "", errors.New("itunes: track has no location")
+	}
+	loc, err := url.Parse(track.Location)
+	if err != nil {
+		return "", err
+	}
+	if loc.Scheme != "file" {
+		return "", errors.New("itunes: track location is not a local file: " + track.Location)
+	}
+	return loc.Path, nil
+}
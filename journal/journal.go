@@ -0,0 +1,183 @@
+package journal
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/types"
+)
+
+const journalLoc = ".cache/journal"
+
+// Entry is a single mutation made by a command, with enough detail to reverse it.
+type Entry struct {
+	RunID   string
+	Command string
+	// Undoes is the run being reversed, for entries written by undo.
+	Undoes string `json:",omitempty"`
+	Time   time.Time
+	Kind   plan.Kind
+	// Path is the file that was changed, deleted or moved.
+	Path string `json:",omitempty"`
+	// To is the new location of a moved file.
+	To      string        `json:",omitempty"`
+	Changes []plan.Change `json:",omitempty"`
+	// UserID, PlaylistID, TrackID and Positions identify a Spotify playlist change. Positions are the indexes the
+	// track was at before it was removed.
+	UserID       string `json:",omitempty"`
+	PlaylistID   string `json:",omitempty"`
+	PlaylistName string `json:",omitempty"`
+	TrackID      string `json:",omitempty"`
+	ArtistID     string `json:",omitempty"`
+	Positions    []int  `json:",omitempty"`
+	// Recording is the recording whose state was changed.
+	Recording *types.RecordingKey `json:",omitempty"`
+	// ITunesTrackID is the database ID of an iTunes track whose location was changed from Path to To.
+	ITunesTrackID int `json:",omitempty"`
+}
+
+// Journal appends entries for a single run to the journal file.
+type Journal struct {
+	mu      sync.Mutex
+	RunID   string
+	Command string
+	Undoes  string
+	file    *os.File
+}
+
+// NewRunID returns an identifier for a run, based on the time it started. A random suffix keeps runs started in the
+// same millisecond apart.
+func NewRunID() string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return time.Now().Format("20060102-150405.000") + "-" + hex.EncodeToString(suffix)
+}
+
+// Record appends an entry to the journal of the context, if there is one. Failing to write the journal is logged
+// rather than returned, as the mutation it describes has already happened.
+func Record(ctx context.Context, entry Entry) {
+	journal := ContextJournal(ctx)
+	if journal == nil {
+		return
+	}
+	err := journal.write(entry)
+	if err != nil {
+		log.WithError(err).WithField("entry", entry).Error("Failed to write journal entry")
+	}
+}
+
+func (journal *Journal) write(entry Entry) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.file == nil {
+		err := os.MkdirAll(filepath.Dir(journalLoc), 0755)
+		if err != nil {
+			return fail.Trace(err)
+		}
+		journal.file, err = os.OpenFile(journalLoc, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fail.Trace(err)
+		}
+	}
+	entry.RunID = journal.RunID
+	entry.Command = journal.Command
+	entry.Undoes = journal.Undoes
+	entry.Time = time.Now()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fail.Trace(err)
+	}
+	_, err = journal.file.Write(append(line, '\n'))
+	return fail.Trace(err)
+}
+
+// Close closes the journal file, if any entries were written.
+func (journal *Journal) Close() error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.file == nil {
+		return nil
+	}
+	return journal.file.Close()
+}
+
+// Load returns every entry in the journal file, oldest first.
+func Load() ([]Entry, error) {
+	file, err := os.Open(journalLoc)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	defer file.Close()
+	entries := []Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		entry := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("journal: invalid entry on line %d: %s", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, fail.Trace(scanner.Err())
+}
+
+// LastRun returns the ID of the most recent run that hasn't been undone, excluding undo runs themselves.
+func LastRun(entries []Entry) string {
+	undone := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Undoes != "" {
+			undone[entry.Undoes] = true
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Undoes == "" && !undone[entry.RunID] {
+			return entry.RunID
+		}
+	}
+	return ""
+}
+
+// RunEntries returns the entries of a single run, oldest first.
+func RunEntries(entries []Entry, runID string) []Entry {
+	run := []Entry{}
+	for _, entry := range entries {
+		if entry.RunID == runID {
+			run = append(run, entry)
+		}
+	}
+	return run
+}
+
+type contextKey int
+
+const journalKey contextKey = iota
+
+// ContextWithJournal returns a new context that records mutations to the journal under the supplied run.
+func ContextWithJournal(ctx context.Context, journal *Journal) context.Context {
+	return context.WithValue(ctx, journalKey, journal)
+}
+
+// ContextJournal returns the journal for the supplied context, or nil if mutations aren't being journaled.
+func ContextJournal(ctx context.Context) *Journal {
+	val := ctx.Value(journalKey)
+	if val != nil {
+		return val.(*Journal)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package journal
+
+import "testing"
+
+func TestLastRun(t *testing.T) {
+	entries := []Entry{
+		{RunID: "1", Command: "tag-files"},
+		{RunID: "2", Command: "remove-unwanted"},
+		{RunID: "2", Command: "remove-unwanted"},
+		{RunID: "3", Command: "undo", Undoes: "2"},
+	}
+	if run := LastRun(entries); run != "1" {
+		t.Fatalf("Expected run 1 but got %q", run)
+	}
+	if run := LastRun(entries[:3]); run != "2" {
+		t.Fatalf("Expected run 2 but got %q", run)
+	}
+	if run := LastRun(nil); run != "" {
+		t.Fatalf("Expected no run but got %q", run)
+	}
+	if count := len(RunEntries(entries, "2")); count != 2 {
+		t.Fatalf("Expected 2 entries for run 2 but got %d", count)
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	if a, b := NewRunID(), NewRunID(); a == b {
+		t.Fatalf("Expected runs started together to have different IDs but both were %q", a)
+	}
+}
@@ -2,18 +2,21 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 
 	"github.com/snikch/api/log"
 	"github.com/snikch/musicmanager/commands"
-	"github.com/snikch/musicmanager/commands/follow_artists"
+	_ "github.com/snikch/musicmanager/commands/follow_artists"
 	"github.com/snikch/musicmanager/configstore"
 	"github.com/snikch/musicmanager/configuration"
-	"github.com/snikch/musicmanager/spotifyclient"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run executes the requested command and returns the exit code, persisting the configuration on the way out.
+func run() int {
 	ctx := configuration.ContextWithConfiguration(context.Background())
 	defer func() {
 		log.Debug("Saving configuration to file")
@@ -23,36 +26,7 @@ func main() {
 			log.WithError(err).Error("Could not save configuration")
 		}
 	}()
-	ctx, err := spotifyclient.ContextWithClient(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
 
 	log.WithField("os.Args", os.Args).Debug("Args")
-	if len(os.Args) <= 1 {
-		displayHelp()
-	}
-
-	switch os.Args[1] {
-	case "follow-artists":
-		err = follow_artists.Command(ctx)
-	case "refresh-spotify":
-		err = commands.RefreshSpotify(ctx)
-	case "tag-files":
-		err = commands.TagFiles(ctx)
-	case "remove-unwanted":
-		err = commands.RemoveUnwanted(ctx)
-	case "create-missing-playlist":
-		err = commands.CreateMissingPlaylist(ctx)
-	default:
-		displayHelp()
-	}
-	if err != nil {
-		log.WithError(err).Fatal()
-	}
-}
-
-func displayHelp() {
-	fmt.Println("Select an arg: refresh-spotify tag-files create-missing-playlist remove-unwanted follow-artists")
-	os.Exit(1)
+	return commands.Run(ctx, os.Args[1:])
 }
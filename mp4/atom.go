@@ -0,0 +1,209 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidAtom is returned when an atom's size doesn't fit inside its parent.
+var ErrInvalidAtom = errors.New("mp4: invalid atom")
+
+// containers are the atoms whose payload is a list of child atoms that may need to be read or changed.
+var containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"udta": true,
+	"meta": true,
+	"ilst": true,
+}
+
+// atom is a single MP4 box. Leaf atoms keep their payload in data, and containers their children. A meta atom may
+// also have a four byte version and flags in data before its children.
+type atom struct {
+	kind     string
+	data     []byte
+	children []*atom
+}
+
+// isContainer returns true if an atom of the supplied kind holds child atoms. Every item in an ilst is a container
+// of data, mean and name atoms.
+func isContainer(kind, parent string) bool {
+	return containers[kind] || parent == "ilst"
+}
+
+// parseAtoms reads every atom in the buffer, which is the payload of an atom of the parent kind.
+func parseAtoms(buf []byte, parent string) ([]*atom, error) {
+	atoms := []*atom{}
+	for len(buf) > 0 {
+		if len(buf) < 8 {
+			return nil, ErrInvalidAtom
+		}
+		size := uint64(binary.BigEndian.Uint32(buf))
+		kind := string(buf[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(buf))
+		case 1:
+			if len(buf) < 16 {
+				return nil, ErrInvalidAtom
+			}
+			size = binary.BigEndian.Uint64(buf[8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(buf)) {
+			return nil, fmt.Errorf("%s: %q of %d bytes in %d", ErrInvalidAtom, kind, size, len(buf))
+		}
+		a, err := parseAtom(kind, buf[header:size], parent)
+		if err != nil {
+			return nil, err
+		}
+		atoms = append(atoms, a)
+		buf = buf[size:]
+	}
+	return atoms, nil
+}
+
+// parseAtom reads the payload of an atom of the supplied kind.
+func parseAtom(kind string, payload []byte, parent string) (*atom, error) {
+	a := &atom{kind: kind}
+	if !isContainer(kind, parent) {
+		a.data = append([]byte{}, payload...)
+		return a, nil
+	}
+	// iTunes writes meta as a full box with a version and flags, while QuickTime starts straight with the hdlr.
+	if kind == "meta" && !(len(payload) >= 8 && string(payload[4:8]) == "hdlr") {
+		if len(payload) < 4 {
+			return nil, ErrInvalidAtom
+		}
+		a.data = append([]byte{}, payload[:4]...)
+		payload = payload[4:]
+	}
+	children, err := parseAtoms(payload, kind)
+	if err != nil {
+		return nil, err
+	}
+	a.children = children
+	return a, nil
+}
+
+// size returns the length of the atom including its header.
+func (a *atom) size() uint64 {
+	size := uint64(len(a.data))
+	for _, child := range a.children {
+		size += child.size()
+	}
+	if size+8 > 0xFFFFFFFF {
+		return size + 16
+	}
+	return size + 8
+}
+
+// bytes returns the atom including its header.
+func (a *atom) bytes() []byte {
+	size := a.size()
+	var out []byte
+	if size > 0xFFFFFFFF {
+		out = make([]byte, 16, size)
+		binary.BigEndian.PutUint32(out, 1)
+		binary.BigEndian.PutUint64(out[8:], size)
+	} else {
+		out = make([]byte, 8, size)
+		binary.BigEndian.PutUint32(out, uint32(size))
+	}
+	copy(out[4:8], a.kind)
+	out = append(out, a.data...)
+	for _, child := range a.children {
+		out = append(out, child.bytes()...)
+	}
+	return out
+}
+
+// child returns the first child of the supplied kind.
+func (a *atom) child(kind string) *atom {
+	for _, child := range a.children {
+		if child.kind == kind {
+			return child
+		}
+	}
+	return nil
+}
+
+// path returns the descendant found by following the supplied kinds, or nil if there isn't one.
+func (a *atom) path(kinds ...string) *atom {
+	for _, kind := range kinds {
+		if a == nil {
+			return nil
+		}
+		a = a.child(kind)
+	}
+	return a
+}
+
+// remove deletes every child of the supplied kind.
+func (a *atom) remove(kind string) {
+	children := a.children[:0]
+	for _, child := range a.children {
+		if child.kind != kind {
+			children = append(children, child)
+		}
+	}
+	a.children = children
+}
+
+// shiftChunkOffsets adds delta to every chunk offset of every track that points at or after the supplied position,
+// as happens to the audio when the atoms before it change size.
+func shiftChunkOffsets(moov *atom, after uint64, delta int64) error {
+	for _, trak := range moov.children {
+		if trak.kind != "trak" {
+			continue
+		}
+		stbl := trak.path("mdia", "minf", "stbl")
+		if stbl == nil {
+			continue
+		}
+		for _, table := range stbl.children {
+			width := 0
+			switch table.kind {
+			case "stco":
+				width = 4
+			case "co64":
+				width = 8
+			default:
+				continue
+			}
+			if len(table.data) < 8 {
+				return ErrInvalidAtom
+			}
+			count := int(binary.BigEndian.Uint32(table.data[4:]))
+			if len(table.data) < 8+count*width {
+				return ErrInvalidAtom
+			}
+			for i := 0; i < count; i++ {
+				entry := table.data[8+i*width:]
+				if width == 4 {
+					offset := uint64(binary.BigEndian.Uint32(entry))
+					if offset < after {
+						continue
+					}
+					shifted := int64(offset) + delta
+					if shifted < 0 || shifted > 0xFFFFFFFF {
+						return errors.New("mp4: chunk offset out of range, the file needs a co64 table")
+					}
+					binary.BigEndian.PutUint32(entry, uint32(shifted))
+				} else {
+					offset := binary.BigEndian.Uint64(entry)
+					if offset < after {
+						continue
+					}
+					binary.BigEndian.PutUint64(entry, uint64(int64(offset)+delta))
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata item atoms written by iTunes.
+const (
+	Title   = "\xa9nam"
+	Artist  = "\xa9ART"
+	Genre   = "\xa9gen"
+	Year    = "\xa9day"
+	Comment = "\xa9cmt"
+	// Rating is the star rating from 0 to 100, as written by Mp3tag and MediaMonkey.
+	Rating = "rate"
+	// genreID is the ID3v1 genre number plus one, used by iTunes for standard genres instead of Genre.
+	genreID  = "gnre"
+	freeform = "----"
+)
+
+// iTunesMean is the namespace of the freeform items written by iTunes, such as the ISRC.
+const iTunesMean = "com.apple.iTunes"
+
+// typeUTF8 is the data atom type of text values.
+const typeUTF8 = 1
+
+// ErrNoMovie is returned when a file has no moov atom, so isn't an MP4 file.
+var ErrNoMovie = errors.New("mp4: no moov atom found")
+
+// File is the metadata of an MP4 file. Changes are only written to disk by Save.
+type File struct {
+	path string
+	moov *atom
+}
+
+// topAtom is the position of an atom at the top level of a file.
+type topAtom struct {
+	kind   string
+	offset int64
+	size   int64
+}
+
+// Open reads the metadata of the MP4 file at the supplied path.
+func Open(path string) (*File, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	atoms, err := readTopAtoms(source)
+	if err != nil {
+		return nil, err
+	}
+	for _, top := range atoms {
+		if top.kind != "moov" {
+			continue
+		}
+		buf := make([]byte, top.size)
+		_, err = source.ReadAt(buf, top.offset)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseAtoms(buf, "")
+		if err != nil {
+			return nil, err
+		}
+		return &File{path: path, moov: parsed[0]}, nil
+	}
+	return nil, ErrNoMovie
+}
+
+// readTopAtoms returns the position of every atom at the top level of the file, without reading their payloads.
+func readTopAtoms(source *os.File) ([]topAtom, error) {
+	stat, err := source.Stat()
+	if err != nil {
+		return nil, err
+	}
+	atoms := []topAtom{}
+	header := make([]byte, 16)
+	for offset := int64(0); offset < stat.Size(); {
+		if stat.Size()-offset < 8 {
+			return nil, ErrInvalidAtom
+		}
+		_, err := source.ReadAt(header[:8], offset)
+		if err != nil {
+			return nil, err
+		}
+		top := topAtom{kind: string(header[4:8]), offset: offset, size: int64(binary.BigEndian.Uint32(header))}
+		switch top.size {
+		case 0:
+			top.size = stat.Size() - offset
+		case 1:
+			_, err := source.ReadAt(header[8:16], offset+8)
+			if err != nil {
+				return nil, err
+			}
+			top.size = int64(binary.BigEndian.Uint64(header[8:]))
+		}
+		if top.size < 8 || offset+top.size > stat.Size() {
+			return nil, ErrInvalidAtom
+		}
+		atoms = append(atoms, top)
+		offset += top.size
+	}
+	return atoms, nil
+}
+
+// Duration returns the length of the movie from its mvhd atom, or 0 if it isn't known.
+func (file *File) Duration() time.Duration {
+	mvhd := file.moov.child("mvhd")
+	if mvhd == nil || len(mvhd.data) < 20 {
+		return 0
+	}
+	// Version 1 has 64 bit creation and modification times and duration, version 0 32 bit ones.
+	var scale, length uint64
+	if mvhd.data[0] == 1 {
+		if len(mvhd.data) < 32 {
+			return 0
+		}
+		scale = uint64(binary.BigEndian.Uint32(mvhd.data[20:]))
+		length = binary.BigEndian.Uint64(mvhd.data[24:])
+	} else {
+		scale = uint64(binary.BigEndian.Uint32(mvhd.data[12:]))
+		length = uint64(binary.BigEndian.Uint32(mvhd.data[16:]))
+		if length == 0xFFFFFFFF {
+			return 0
+		}
+	}
+	if scale == 0 {
+		return 0
+	}
+	return time.Duration(float64(length) / float64(scale) * float64(time.Second))
+}
+
+// ilst returns the metadata item list, creating it if required and create is true.
+func (file *File) ilst(create bool) *atom {
+	ilst := file.moov.path("udta", "meta", "ilst")
+	if ilst != nil || !create {
+		return ilst
+	}
+	udta := file.moov.child("udta")
+	if udta == nil {
+		udta = &atom{kind: "udta"}
+		file.moov.children = append(file.moov.children, udta)
+	}
+	meta := udta.child("meta")
+	if meta == nil {
+		// The handler marks the metadata as iTunes' item list: no predefined value, "mdir", "appl" and no name.
+		hdlr := make([]byte, 25)
+		copy(hdlr[8:], "mdirappl")
+		meta = &atom{kind: "meta", data: make([]byte, 4), children: []*atom{{kind: "hdlr", data: hdlr}}}
+		udta.children = append(udta.children, meta)
+	}
+	ilst = &atom{kind: "ilst"}
+	meta.children = append(meta.children, ilst)
+	return ilst
+}
+
+// value returns the type and value of the item's data atom.
+func value(item *atom) (uint32, []byte, bool) {
+	if item == nil {
+		return 0, nil, false
+	}
+	data := item.child("data")
+	if data == nil || len(data.data) < 8 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint32(data.data) & 0xFFFFFF, data.data[8:], true
+}
+
+// dataAtom returns a data atom holding the supplied value.
+func dataAtom(kind uint32, value []byte) *atom {
+	data := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint32(data, kind)
+	return &atom{kind: "data", data: append(data, value...)}
+}
+
+// Text returns the value of a text item, such as Title.
+func (file *File) Text(kind string) string {
+	ilst := file.ilst(false)
+	if ilst == nil {
+		return ""
+	}
+	_, value, _ := value(ilst.child(kind))
+	return string(value)
+}
+
+// SetText sets the value of a text item, removing it if the value is empty.
+func (file *File) SetText(kind, text string) {
+	ilst := file.ilst(text != "")
+	if ilst == nil {
+		return
+	}
+	ilst.remove(kind)
+	if text != "" {
+		ilst.children = append(ilst.children, &atom{kind: kind, children: []*atom{dataAtom(typeUTF8, []byte(text))}})
+	}
+}
+
+// freeformItem returns the freeform item with the supplied namespace and name.
+func (file *File) freeformItem(mean, name string) *atom {
+	ilst := file.ilst(false)
+	if ilst == nil {
+		return nil
+	}
+	for _, item := range ilst.children {
+		if item.kind != freeform {
+			continue
+		}
+		itemMean, itemName := item.child("mean"), item.child("name")
+		if itemMean == nil || itemName == nil || len(itemMean.data) < 4 || len(itemName.data) < 4 {
+			continue
+		}
+		if string(itemMean.data[4:]) == mean && string(itemName.data[4:]) == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// Freeform returns the value of a freeform item, such as iTunes' ISRC.
+func (file *File) Freeform(mean, name string) string {
+	_, value, _ := value(file.freeformItem(mean, name))
+	return string(value)
+}
+
+// SetFreeform sets the value of a freeform item, removing it if the value is empty.
+func (file *File) SetFreeform(mean, name, text string) {
+	if item := file.freeformItem(mean, name); item != nil {
+		ilst := file.ilst(false)
+		children := ilst.children[:0]
+		for _, child := range ilst.children {
+			if child != item {
+				children = append(children, child)
+			}
+		}
+		ilst.children = children
+	}
+	if text == "" {
+		return
+	}
+	ilst := file.ilst(true)
+	ilst.children = append(ilst.children, &atom{kind: freeform, children: []*atom{
+		{kind: "mean", data: append(make([]byte, 4), mean...)},
+		{kind: "name", data: append(make([]byte, 4), name...)},
+		dataAtom(typeUTF8, []byte(text)),
+	}})
+}
+
+// Genre returns the genre, from the text item or the standard genre number.
+func (file *File) Genre() string {
+	if genre := file.Text(Genre); genre != "" {
+		return genre
+	}
+	ilst := file.ilst(false)
+	if ilst == nil {
+		return ""
+	}
+	_, value, ok := value(ilst.child(genreID))
+	if !ok || len(value) < 2 {
+		return ""
+	}
+	id := int(binary.BigEndian.Uint16(value))
+	if id < 1 || id > len(genres) {
+		return ""
+	}
+	return genres[id-1]
+}
+
+// SetGenre sets the genre as text, replacing any standard genre number.
+func (file *File) SetGenre(genre string) {
+	if ilst := file.ilst(false); ilst != nil {
+		ilst.remove(genreID)
+	}
+	file.SetText(Genre, genre)
+}
+
+// ISRC returns the International Standard Recording Code of the recording.
+func (file *File) ISRC() string {
+	return file.Freeform(iTunesMean, "ISRC")
+}
+
+// SetISRC sets the International Standard Recording Code of the recording.
+func (file *File) SetISRC(isrc string) {
+	file.SetFreeform(iTunesMean, "ISRC", isrc)
+}
+
+// Rating returns the star rating from 0 to 100, or 0 if the file isn't rated.
+func (file *File) Rating() int {
+	rating, _ := strconv.Atoi(strings.TrimSpace(file.Text(Rating)))
+	return rating
+}
+
+// SetRating sets the star rating from 0 to 100, removing it if the rating is 0.
+func (file *File) SetRating(rating int) {
+	if rating <= 0 {
+		file.SetText(Rating, "")
+		return
+	}
+	file.SetText(Rating, strconv.Itoa(rating))
+}
+
+// Save writes the metadata back to the file. The file is rewritten next to the original and then moved over it,
+// so it's never left half written. When the metadata changes size, free space after the moov atom is used up (or
+// added to) where possible, otherwise the chunk offsets of the audio after it are moved to match.
+func (file *File) Save() error {
+	source, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	stat, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	atoms, err := readTopAtoms(source)
+	if err != nil {
+		return err
+	}
+	moovIndex := -1
+	for i, top := range atoms {
+		if top.kind == "moov" {
+			moovIndex = i
+			break
+		}
+	}
+	if moovIndex < 0 {
+		return ErrNoMovie
+	}
+	moov := atoms[moovIndex]
+	delta := int64(file.moov.size()) - moov.size
+	// free is the new size of the free atom after the moov, or -1 if there isn't one to use.
+	free := int64(-1)
+	if delta != 0 && moovIndex+1 < len(atoms) {
+		next := atoms[moovIndex+1]
+		if (next.kind == "free" || next.kind == "skip") && (next.size-delta >= 8 || next.size-delta == 0) {
+			free = next.size - delta
+			delta = 0
+		}
+	}
+	if delta != 0 {
+		err = shiftChunkOffsets(file.moov, uint64(moov.offset+moov.size), delta)
+		if err != nil {
+			return err
+		}
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(file.path), "."+filepath.Base(file.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	err = writeAtoms(temp, source, atoms, moovIndex, file.moov.bytes(), free)
+	if err == nil {
+		err = temp.Chmod(stat.Mode())
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file.path)
+}
+
+// writeAtoms copies the top level atoms to the destination, replacing the moov atom and resizing the free atom after
+// it if free isn't -1.
+func writeAtoms(dest io.Writer, source *os.File, atoms []topAtom, moovIndex int, moov []byte, free int64) error {
+	for i, top := range atoms {
+		switch {
+		case i == moovIndex:
+			_, err := dest.Write(moov)
+			if err != nil {
+				return err
+			}
+		case i == moovIndex+1 && free >= 0:
+			if free == 0 {
+				continue
+			}
+			padding := make([]byte, free)
+			binary.BigEndian.PutUint32(padding, uint32(free))
+			copy(padding[4:], "free")
+			_, err := dest.Write(padding)
+			if err != nil {
+				return err
+			}
+		default:
+			_, err := io.Copy(dest, io.NewSectionReader(source, top.offset, top.size))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testAudio = []byte("AUDIO FRAMES")
+
+// testAtom returns an atom of the supplied kind with the payloads concatenated.
+func testAtom(kind string, payloads ...[]byte) []byte {
+	payload := bytes.Join(payloads, nil)
+	out := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(out, uint32(8+len(payload)))
+	copy(out[4:], kind)
+	return append(out, payload...)
+}
+
+func testItem(kind string, dataType uint32, value []byte) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, dataType)
+	return testAtom(kind, testAtom("data", data, value))
+}
+
+// testFile writes an MP4 file with a title, a standard genre and a single chunk of audio, with the moov atom before
+// the mdat and padding of the supplied size between them. It returns the path of the file.
+func testFile(t *testing.T, padding int) string {
+	ftyp := testAtom("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42isom"))
+	hdlr := testAtom("hdlr", make([]byte, 8), []byte("mdirappl"), make([]byte, 9))
+	ilst := testAtom("ilst",
+		testItem(Title, typeUTF8, []byte("Song (Extended Mix)")),
+		testItem(genreID, 0, []byte{0, 36}),
+	)
+	udta := testAtom("udta", testAtom("meta", make([]byte, 4), hdlr, ilst))
+	// The offset is filled in once the position of the audio is known.
+	stco := make([]byte, 12)
+	binary.BigEndian.PutUint32(stco[4:], 1)
+	moov := func(offset uint32) []byte {
+		binary.BigEndian.PutUint32(stco[8:], offset)
+		trak := testAtom("trak", testAtom("mdia", testAtom("minf", testAtom("stbl", testAtom("stco", stco)))))
+		return testAtom("moov", trak, udta)
+	}
+	free := []byte{}
+	if padding > 0 {
+		free = testAtom("free", make([]byte, padding-8))
+	}
+	offset := len(ftyp) + len(moov(0)) + len(free) + 8
+	contents := bytes.Join([][]byte{ftyp, moov(uint32(offset)), free, testAtom("mdat", testAudio)}, nil)
+	path := filepath.Join(t.TempDir(), "song.m4a")
+	err := ioutil.WriteFile(path, contents, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// checkAudio fails the test if the chunk offset of the file doesn't point at the audio.
+func checkAudio(t *testing.T, path string) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stco := file.moov.path("trak", "mdia", "minf", "stbl", "stco")
+	offset := binary.BigEndian.Uint32(stco.data[8:])
+	if !bytes.HasPrefix(contents[offset:], testAudio) {
+		t.Fatalf("Expected the chunk offset %d to point at the audio", offset)
+	}
+}
+
+func TestReadTags(t *testing.T) {
+	file, err := Open(testFile(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Text(Title) != "Song (Extended Mix)" {
+		t.Fatalf("Expected the title, got %q", file.Text(Title))
+	}
+	if file.Genre() != "House" {
+		t.Fatalf("Expected the standard genre House, got %q", file.Genre())
+	}
+	if file.Text(Artist) != "" || file.ISRC() != "" || file.Rating() != 0 {
+		t.Fatal("Expected missing items to be empty")
+	}
+}
+
+func TestSaveTags(t *testing.T) {
+	for name, padding := range map[string]int{"shifted": 0, "padded": 1024} {
+		t.Run(name, func(t *testing.T) {
+			path := testFile(t, padding)
+			before, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.SetText(Artist, "Someone")
+			file.SetText(Comment, "Rating: 4")
+			file.SetText(Year, "2017")
+			file.SetGenre("house vocal")
+			file.SetISRC("GBABC1700001")
+			file.SetRating(80)
+			err = file.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkAudio(t, path)
+			after, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if padding > 0 && after.Size() != before.Size() {
+				t.Fatalf("Expected the padding to be used, but the file grew from %d to %d", before.Size(), after.Size())
+			}
+
+			file, err = Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for kind, expected := range map[string]string{
+				Title:   "Song (Extended Mix)",
+				Artist:  "Someone",
+				Comment: "Rating: 4",
+				Year:    "2017",
+			} {
+				if file.Text(kind) != expected {
+					t.Fatalf("Expected %q to be %q, got %q", kind, expected, file.Text(kind))
+				}
+			}
+			if file.Genre() != "house vocal" || file.ilst(false).child(genreID) != nil {
+				t.Fatalf("Expected the standard genre to be replaced, got %q", file.Genre())
+			}
+			if file.ISRC() != "GBABC1700001" || file.Rating() != 80 {
+				t.Fatalf("Expected the ISRC and rating, got %q and %d", file.ISRC(), file.Rating())
+			}
+
+			// Removing items shrinks the moov atom.
+			file.SetText(Comment, "")
+			file.SetISRC("")
+			err = file.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkAudio(t, path)
+			file, err = Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Text(Comment) != "" || file.ISRC() != "" || file.Text(Artist) != "Someone" {
+				t.Fatal("Expected only the comment and ISRC to be removed")
+			}
+		})
+	}
+}
+
+func TestCreateMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bare.m4a")
+	// The moov is after the audio, so the chunk offset doesn't change when it grows.
+	stco := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 8}
+	moov := testAtom("moov", testAtom("trak", testAtom("mdia", testAtom("minf", testAtom("stbl", testAtom("stco", stco))))))
+	err := ioutil.WriteFile(path, append(testAtom("mdat", testAudio), moov...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.SetText(Title, "Song")
+	err = file.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAudio(t, path)
+	file, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Text(Title) != "Song" || file.moov.path("udta", "meta", "hdlr") == nil {
+		t.Fatalf("Expected a new item list with the title, got %q", file.Text(Title))
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.m4a")
+	err := ioutil.WriteFile(path, testAtom("mdat", testAudio), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err != ErrNoMovie {
+		t.Fatalf("Expected ErrNoMovie, got %v", err)
+	}
+	err = ioutil.WriteFile(path, []byte{0, 0, 1, 0, 'm', 'o', 'o', 'v'}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err != ErrInvalidAtom {
+		t.Fatalf("Expected ErrInvalidAtom, got %v", err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	file, err := Open(testFile(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Duration() != 0 {
+		t.Fatalf("Expected no duration without an mvhd atom, got %v", file.Duration())
+	}
+	// A version 0 mvhd with a timescale of 1000 per second.
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 372500)
+	path := filepath.Join(t.TempDir(), "song.m4a")
+	err = ioutil.WriteFile(path, bytes.Join([][]byte{testAtom("moov", testAtom("mvhd", mvhd)), testAtom("mdat", testAudio)}, nil), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 6*time.Minute + 12500*time.Millisecond; file.Duration() != expected {
+		t.Fatalf("Expected %v, got %v", expected, file.Duration())
+	}
+}
@@ -0,0 +1,37 @@
+package mpeg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// AudioHash returns a hash of the audio frames of the MPEG file at the supplied path, ignoring any ID3 tags, so two
+// copies of a file with different tags have the same hash.
+func AudioHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	info, err := ReadInfo(file, stat.Size())
+	if err != nil {
+		return "", err
+	}
+	return HashInfo(file, info)
+}
+
+// HashInfo returns a hash of the audio frames described by the info, read from the stream it was read from.
+func HashInfo(r io.ReaderAt, info Info) (string, error) {
+	hash := sha1.New()
+	_, err := io.Copy(hash, io.NewSectionReader(r, info.AudioOffset, info.AudioSize))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
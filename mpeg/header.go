@@ -0,0 +1,120 @@
+package mpeg
+
+import "errors"
+
+// Version is the MPEG audio version of a frame.
+type Version int
+
+const (
+	Version25 Version = iota
+	versionReserved
+	Version2
+	Version1
+)
+
+func (version Version) String() string {
+	switch version {
+	case Version1:
+		return "MPEG-1"
+	case Version2:
+		return "MPEG-2"
+	case Version25:
+		return "MPEG-2.5"
+	}
+	return "reserved"
+}
+
+// Channel modes of a frame.
+const (
+	ChannelStereo      = 0
+	ChannelJointStereo = 1
+	ChannelDual        = 2
+	ChannelMono        = 3
+)
+
+var (
+	errInvalidHeader = errors.New("mpeg: invalid frame header")
+
+	// bitrates in kbps, indexed by [version 1 or 2][layer][index]. MPEG-2.5 shares the MPEG-2 table.
+	bitrates = [2][4][16]int{
+		{
+			{},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		},
+		{
+			{},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		},
+	}
+	sampleRates = map[Version][3]int{
+		Version1:  {44100, 48000, 32000},
+		Version2:  {22050, 24000, 16000},
+		Version25: {11025, 12000, 8000},
+	}
+)
+
+// Header is a decoded MPEG audio frame header.
+type Header struct {
+	Version     Version
+	Layer       int
+	Bitrate     int
+	SampleRate  int
+	Padding     bool
+	ChannelMode int
+}
+
+// ParseHeader decodes the four byte frame header at the start of the supplied bytes.
+func ParseHeader(b []byte) (Header, error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return Header{}, errInvalidHeader
+	}
+	header := Header{
+		Version:     Version(b[1] >> 3 & 0x03),
+		Layer:       4 - int(b[1]>>1&0x03),
+		Padding:     b[2]>>1&0x01 == 1,
+		ChannelMode: int(b[3] >> 6),
+	}
+	if header.Version == versionReserved || header.Layer == 4 {
+		return Header{}, errInvalidHeader
+	}
+	table := 1
+	if header.Version == Version1 {
+		table = 0
+	}
+	bitrateIndex := b[2] >> 4
+	rateIndex := b[2] >> 2 & 0x03
+	if bitrateIndex == 0 || bitrateIndex == 0x0F || rateIndex == 0x03 {
+		return Header{}, errInvalidHeader
+	}
+	// The tables are stored with layer 3 first.
+	header.Bitrate = bitrates[table][4-header.Layer][bitrateIndex]
+	header.SampleRate = sampleRates[header.Version][rateIndex]
+	return header, nil
+}
+
+// SamplesPerFrame returns the number of audio samples encoded in each frame.
+func (header Header) SamplesPerFrame() int {
+	switch {
+	case header.Layer == 1:
+		return 384
+	case header.Layer == 3 && header.Version != Version1:
+		return 576
+	}
+	return 1152
+}
+
+// FrameLength returns the length of the frame in bytes, including the header.
+func (header Header) FrameLength() int {
+	padding := 0
+	if header.Padding {
+		padding = 1
+	}
+	if header.Layer == 1 {
+		return (12*header.Bitrate*1000/header.SampleRate + padding) * 4
+	}
+	return header.SamplesPerFrame()/8*header.Bitrate*1000/header.SampleRate + padding
+}
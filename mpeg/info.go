@@ -0,0 +1,164 @@
+package mpeg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	id3v2HeaderLength = 10
+	id3v1Length       = 128
+	// searchLength is how far into the audio to look for the first frame.
+	searchLength = 64 * 1024
+)
+
+// ErrNoFrames is returned when no MPEG audio frames could be found.
+var ErrNoFrames = errors.New("mpeg: no audio frames found")
+
+// Info describes the audio stream of an MPEG file.
+type Info struct {
+	Header
+	// AudioOffset is the position of the first frame, after any ID3v2 tag.
+	AudioOffset int64
+	// AudioSize is the number of bytes of audio frames, excluding tags.
+	AudioSize int64
+	Duration  time.Duration
+	Mode      BitrateMode
+	// AverageBitrate is the bitrate in kbps across the whole stream, which is the frame bitrate for CBR streams.
+	AverageBitrate int
+	// Frames is the number of audio frames from the VBR header, or 0 if the stream doesn't have one.
+	Frames int64
+	// Encoder is the encoder version from the LAME tag, such as "LAME3.99r", if there is one.
+	Encoder string
+}
+
+// Probe reads the audio information of the MPEG file at the supplied path.
+func Probe(path string) (Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return Info{}, err
+	}
+	return ReadInfo(file, stat.Size())
+}
+
+// ReadInfo reads the audio information of an MPEG stream of the supplied size. The duration and average bitrate come
+// from the Xing, Info or VBRI header of the first frame, or are estimated from its bitrate if it doesn't have one.
+func ReadInfo(r io.ReadSeeker, size int64) (Info, error) {
+	info := Info{}
+	offset, err := skipID3v2(r)
+	if err != nil {
+		return info, err
+	}
+	end := size
+	if size-id3v1Length >= offset {
+		tag := make([]byte, 3)
+		_, err = r.Seek(size-id3v1Length, io.SeekStart)
+		if err == nil {
+			_, err = io.ReadFull(r, tag)
+		}
+		if err != nil {
+			return info, err
+		}
+		if string(tag) == "TAG" {
+			end -= id3v1Length
+		}
+	}
+
+	_, err = r.Seek(offset, io.SeekStart)
+	if err != nil {
+		return info, err
+	}
+	buf := make([]byte, searchLength)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return info, err
+	}
+	position, header, err := findFrame(buf[:n])
+	if err != nil {
+		return info, err
+	}
+	info.Header = header
+	info.AudioOffset = offset + int64(position)
+	info.AudioSize = end - info.AudioOffset
+	info.Mode = ModeCBR
+	info.AverageBitrate = header.Bitrate
+	info.Duration = time.Duration(info.AudioSize*8*1000/int64(header.Bitrate)) * time.Microsecond
+	frame := buf[position:n]
+	if length := header.FrameLength(); length < len(frame) {
+		frame = frame[:length]
+	}
+	vbr, ok := parseVBRHeader(header, frame)
+	if !ok {
+		return info, nil
+	}
+	info.Mode = vbr.Mode
+	info.Encoder = vbr.Encoder
+	if vbr.Frames > 0 {
+		info.Frames = vbr.Frames
+		info.Duration = time.Duration(vbr.Frames*int64(header.SamplesPerFrame())) * time.Second / time.Duration(header.SampleRate)
+		audio := vbr.Bytes
+		if audio <= 0 {
+			audio = info.AudioSize
+		}
+		if info.Duration > 0 {
+			info.AverageBitrate = int(float64(audio*8)/info.Duration.Seconds()/1000 + 0.5)
+		}
+	}
+	return info, nil
+}
+
+// skipID3v2 returns the offset of the first byte after any ID3v2 tag at the start of the stream.
+func skipID3v2(r io.ReadSeeker) (int64, error) {
+	header := make([]byte, id3v2HeaderLength)
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.ReadFull(r, header)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return 0, ErrNoFrames
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(header[:3], []byte("ID3")) {
+		return 0, nil
+	}
+	// The tag size is a 28 bit syncsafe integer, excluding the header and optional footer.
+	size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+	offset := id3v2HeaderLength + size
+	if header[5]&0x10 != 0 {
+		offset += id3v2HeaderLength
+	}
+	return offset, nil
+}
+
+// findFrame returns the position of the first valid frame in the buffer. A frame is only considered valid if it is
+// followed by another valid frame, to avoid false syncs in garbage data.
+func findFrame(buf []byte) (int, Header, error) {
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF {
+			continue
+		}
+		header, err := ParseHeader(buf[i:])
+		if err != nil {
+			continue
+		}
+		next := i + header.FrameLength()
+		if next+4 <= len(buf) {
+			if _, err := ParseHeader(buf[next:]); err != nil {
+				continue
+			}
+		}
+		return i, header, nil
+	}
+	return 0, Header{}, ErrNoFrames
+}
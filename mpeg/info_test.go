@@ -0,0 +1,155 @@
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// testFrame returns a silent MPEG-1 layer 3 frame at 128kbps and 44.1kHz, without padding.
+func testFrame() []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return frame
+}
+
+func testStream(frames int, prefix, suffix []byte) []byte {
+	stream := append([]byte{}, prefix...)
+	for i := 0; i < frames; i++ {
+		stream = append(stream, testFrame()...)
+	}
+	return append(stream, suffix...)
+}
+
+func TestParseHeader(t *testing.T) {
+	header, err := ParseHeader(testFrame())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Header{Version: Version1, Layer: 3, Bitrate: 128, SampleRate: 44100, ChannelMode: ChannelStereo}
+	if header != expected {
+		t.Fatalf("Expected %+v but got %+v", expected, header)
+	}
+	if header.FrameLength() != 417 {
+		t.Fatalf("Expected frame length 417 but got %d", header.FrameLength())
+	}
+	for _, invalid := range [][]byte{
+		{0xFF, 0xFB, 0xF0, 0x00},
+		{0xFF, 0xFB, 0x9C, 0x00},
+		{0xFF, 0xE9, 0x90, 0x00},
+		{0x00, 0xFB, 0x90, 0x00},
+	} {
+		if _, err := ParseHeader(invalid); err == nil {
+			t.Fatalf("Expected %x to be invalid", invalid)
+		}
+	}
+}
+
+func TestReadInfo(t *testing.T) {
+	// A 20 byte ID3v2 tag and an ID3v1 tag surrounding ~10 seconds of frames.
+	id3v2 := append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 10}, make([]byte, 10)...)
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	frames := 383
+	stream := testStream(frames, id3v2, id3v1)
+
+	info, err := ReadInfo(bytes.NewReader(stream), int64(len(stream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AudioOffset != 20 {
+		t.Fatalf("Expected audio at 20 but got %d", info.AudioOffset)
+	}
+	if info.AudioSize != int64(frames*417) {
+		t.Fatalf("Expected %d bytes of audio but got %d", frames*417, info.AudioSize)
+	}
+	if info.Duration.Round(100*time.Millisecond) != 10*time.Second {
+		t.Fatalf("Expected a duration of 10s but got %s", info.Duration)
+	}
+}
+
+func TestReadInfoNoFrames(t *testing.T) {
+	stream := bytes.Repeat([]byte{0xFF, 0x00}, 1000)
+	_, err := ReadInfo(bytes.NewReader(stream), int64(len(stream)))
+	if err != ErrNoFrames {
+		t.Fatalf("Expected ErrNoFrames but got %v", err)
+	}
+}
+
+func TestHashInfoIgnoresTags(t *testing.T) {
+	hash := func(stream []byte) string {
+		info, err := ReadInfo(bytes.NewReader(stream), int64(len(stream)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := HashInfo(bytes.NewReader(stream), info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	id3v2 := append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 4}, "TAGS"...)
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	untagged := hash(testStream(10, nil, nil))
+	if tagged := hash(testStream(10, id3v2, id3v1)); tagged != untagged {
+		t.Fatalf("Expected tags to be ignored, got %s and %s", tagged, untagged)
+	}
+	if longer := hash(testStream(11, nil, nil)); longer == untagged {
+		t.Fatal("Expected different audio to have a different hash")
+	}
+}
+
+// vbrFrame returns a first frame carrying a Xing or Info header with the supplied frame and byte counts, and
+// optionally a LAME tag with the supplied VBR method.
+func vbrFrame(tag string, frames, size uint32, lameMethod byte) []byte {
+	frame := testFrame()
+	// MPEG-1 stereo frames have 32 bytes of side information after the header.
+	xing := frame[36:]
+	copy(xing, tag)
+	binary.BigEndian.PutUint32(xing[4:], 0x3)
+	binary.BigEndian.PutUint32(xing[8:], frames)
+	binary.BigEndian.PutUint32(xing[12:], size)
+	if lameMethod != 0 {
+		copy(xing[16:], "LAME3.99r")
+		xing[25] = lameMethod
+	}
+	return frame
+}
+
+func TestReadInfoVBRHeaders(t *testing.T) {
+	// 383 frames of 1152 samples at 44.1kHz is 10 seconds, and 250,000 bytes over 10 seconds is 200kbps.
+	vbri := testFrame()
+	copy(vbri[36:], "VBRI")
+	binary.BigEndian.PutUint32(vbri[36+10:], 250000)
+	binary.BigEndian.PutUint32(vbri[36+14:], 383)
+	for _, test := range []struct {
+		name    string
+		first   []byte
+		mode    BitrateMode
+		bitrate int
+		encoder string
+	}{
+		{"xing", vbrFrame("Xing", 383, 250000, 0), ModeVBR, 200, ""},
+		{"lame vbr", vbrFrame("Xing", 383, 250000, 4), ModeVBR, 200, "LAME3.99r"},
+		{"lame abr", vbrFrame("Xing", 383, 250000, 2), ModeABR, 200, "LAME3.99r"},
+		{"lame cbr", vbrFrame("Info", 383, 160000, 1), ModeCBR, 128, "LAME3.99r"},
+		{"vbri", vbri, ModeVBR, 200, "Fraunhofer"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stream := testStream(20, test.first, nil)
+			info, err := ReadInfo(bytes.NewReader(stream), int64(len(stream)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode != test.mode || info.Encoder != test.encoder || info.Frames != 383 {
+				t.Fatalf("Expected %s from %q with 383 frames, got %s from %q with %d", test.mode, test.encoder, info.Mode, info.Encoder, info.Frames)
+			}
+			if info.Duration.Round(100*time.Millisecond) != 10*time.Second {
+				t.Fatalf("Expected a duration of 10s from the frame count, got %s", info.Duration)
+			}
+			if info.AverageBitrate != test.bitrate {
+				t.Fatalf("Expected an average bitrate of %d, got %d", test.bitrate, info.AverageBitrate)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// BitrateMode is how the bitrate of a stream varies between frames.
+type BitrateMode string
+
+const (
+	// ModeCBR streams have the same bitrate in every frame.
+	ModeCBR BitrateMode = "CBR"
+	// ModeVBR streams vary the bitrate of each frame to keep the quality constant.
+	ModeVBR BitrateMode = "VBR"
+	// ModeABR streams vary the bitrate of each frame around a target average.
+	ModeABR BitrateMode = "ABR"
+)
+
+// vbrHeader is the Xing, Info or VBRI header some encoders write in place of the audio of the first frame.
+type vbrHeader struct {
+	Mode BitrateMode
+	// Frames is the number of audio frames in the stream, and Bytes their size, or 0 if they're not included.
+	Frames  int64
+	Bytes   int64
+	Encoder string
+}
+
+// sideInfoLength returns the length of the layer 3 side information that comes after the frame header.
+func (header Header) sideInfoLength() int {
+	mono := header.ChannelMode == ChannelMono
+	switch {
+	case header.Version == Version1 && mono:
+		return 17
+	case header.Version == Version1:
+		return 32
+	case mono:
+		return 9
+	}
+	return 17
+}
+
+// parseVBRHeader reads the VBR header of the first frame, returning false if it doesn't have one.
+func parseVBRHeader(header Header, frame []byte) (vbrHeader, bool) {
+	if header.Layer != 3 {
+		return vbrHeader{}, false
+	}
+	if vbr, ok := parseXing(frame[minInt(4+header.sideInfoLength(), len(frame)):]); ok {
+		return vbr, true
+	}
+	// VBRI headers, written by Fraunhofer encoders, are always 32 bytes after the frame header.
+	return parseVBRI(frame[minInt(4+32, len(frame)):])
+}
+
+// parseXing reads a Xing header, used for VBR streams, or an Info header, used by LAME for CBR streams. LAME
+// follows either with its own tag, which says whether the stream is actually ABR.
+func parseXing(b []byte) (vbrHeader, bool) {
+	if len(b) < 8 {
+		return vbrHeader{}, false
+	}
+	vbr := vbrHeader{}
+	switch string(b[:4]) {
+	case "Xing":
+		vbr.Mode = ModeVBR
+	case "Info":
+		vbr.Mode = ModeCBR
+	default:
+		return vbrHeader{}, false
+	}
+	flags := binary.BigEndian.Uint32(b[4:])
+	b = b[8:]
+	fields := []struct {
+		flag   uint32
+		length int
+		value  *int64
+	}{
+		{0x1, 4, &vbr.Frames},
+		{0x2, 4, &vbr.Bytes},
+		{0x4, 100, nil},
+		{0x8, 4, nil},
+	}
+	for _, field := range fields {
+		if flags&field.flag == 0 {
+			continue
+		}
+		if len(b) < field.length {
+			return vbrHeader{}, false
+		}
+		if field.value != nil {
+			*field.value = int64(binary.BigEndian.Uint32(b))
+		}
+		b = b[field.length:]
+	}
+	// The LAME tag starts with a nine character encoder version, e.g. "LAME3.99r", followed by the VBR method.
+	if len(b) >= 10 && (bytes.HasPrefix(b, []byte("LAME")) || bytes.HasPrefix(b, []byte("Lavc"))) {
+		vbr.Encoder = strings.TrimRight(string(b[:9]), "\x00 ")
+		switch b[9] & 0x0F {
+		case 1, 8:
+			vbr.Mode = ModeCBR
+		case 2, 9:
+			vbr.Mode = ModeABR
+		case 3, 4, 5, 6:
+			vbr.Mode = ModeVBR
+		}
+	}
+	return vbr, true
+}
+
+// parseVBRI reads a Fraunhofer VBRI header.
+func parseVBRI(b []byte) (vbrHeader, bool) {
+	if len(b) < 18 || string(b[:4]) != "VBRI" {
+		return vbrHeader{}, false
+	}
+	return vbrHeader{
+		Mode:    ModeVBR,
+		Bytes:   int64(binary.BigEndian.Uint32(b[10:])),
+		Frames:  int64(binary.BigEndian.Uint32(b[14:])),
+		Encoder: "Fraunhofer",
+	}, true
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,237 @@
+package music
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+// playlistChanges holds the tracks to add to and remove from a single playlist.
+type playlistChanges struct {
+	Playlist spotifyapi.SimplePlaylist
+	Add      []spotifyapi.ID
+	Remove   []spotifyapi.ID
+}
+
+// BackpropagateTags is the reverse of the genre tag processor. Every local file matched to a Spotify track is added
+// to each playlist whose tags are all present in the file's Genre tag, and removed from matching playlists whose tags
+// are not. It returns true if any playlist was changed. In dry-run mode the changes are recorded on the plan instead.
+func BackpropagateTags(ctx context.Context, graph spotify.TrackGraph, contexts types.FileContexts) (bool, error) {
+	playlists := graphPlaylists(graph)
+	changes := map[spotifyapi.ID]*playlistChanges{}
+	for _, playlist := range playlists {
+		changes[playlist.ID] = &playlistChanges{Playlist: playlist}
+	}
+	p := plan.ContextPlan(ctx)
+	for key, fileContext := range contexts {
+		if fileContext.SpotifyTrack == nil {
+			continue
+		}
+		l := log.WithField("key", key).WithField("genre", fileContext.Genre())
+		current := map[spotifyapi.ID]bool{}
+		for _, playlist := range fileContext.SpotifyPlaylists {
+			current[playlist.ID] = true
+		}
+		target, ambiguous := targetPlaylists(ctx, fileContext.Genre(), playlists)
+		for id := range target {
+			if current[id] {
+				continue
+			}
+			l.WithField("playlist", changes[id].Playlist.Name).Info("Adding track to playlist")
+			changes[id].Add = append(changes[id].Add, fileContext.SpotifyTrack.ID)
+			if p != nil {
+				p.Add(playlistAction(plan.KindPlaylistAdd, key, changes[id].Playlist, fileContext.SpotifyTrack.ID))
+			}
+		}
+		for id := range current {
+			if target[id] || ambiguous[id] || changes[id] == nil {
+				continue
+			}
+			l.WithField("playlist", changes[id].Playlist.Name).Info("Removing track from playlist")
+			changes[id].Remove = append(changes[id].Remove, fileContext.SpotifyTrack.ID)
+			if p != nil {
+				p.Add(playlistAction(plan.KindPlaylistRemove, key, changes[id].Playlist, fileContext.SpotifyTrack.ID))
+			}
+		}
+	}
+	if p != nil {
+		return false, nil
+	}
+
+	didChange := false
+	svc := service.ContextService(ctx)
+	for _, change := range changes {
+		owner := change.Playlist.Owner.ID
+		entry := journal.Entry{
+			UserID:       owner,
+			PlaylistID:   string(change.Playlist.ID),
+			PlaylistName: change.Playlist.Name,
+		}
+		// Playlists can only be modified in lots of 100 tracks.
+		for head := 0; head < len(change.Add); head += 100 {
+			tail := head + 100
+			if tail > len(change.Add) {
+				tail = len(change.Add)
+			}
+			err := svc.AddPlaylistTracks(owner, change.Playlist.ID, change.Add[head:tail]...)
+			if err != nil {
+				return didChange, err
+			}
+			didChange = true
+			for _, id := range change.Add[head:tail] {
+				entry.Kind = plan.KindPlaylistAdd
+				entry.TrackID = string(id)
+				journal.Record(ctx, entry)
+			}
+		}
+		if len(change.Remove) > 0 {
+			// Journal the positions of removed tracks so they can be put back in place.
+			positions, err := spotify.PlaylistPositions(ctx, owner, change.Playlist.ID)
+			if err != nil {
+				return didChange, err
+			}
+			removed := []int{}
+			for _, id := range change.Remove {
+				err := svc.RemovePlaylistTracks(owner, change.Playlist.ID, id)
+				if err != nil {
+					return didChange, err
+				}
+				didChange = true
+				entry.Kind = plan.KindPlaylistRemove
+				entry.TrackID = string(id)
+				entry.Positions = shiftPositions(positions[id], removed)
+				removed = append(removed, positions[id]...)
+				journal.Record(ctx, entry)
+			}
+		}
+		if len(change.Add) > 0 || len(change.Remove) > 0 {
+			log.WithField("playlist", change.Playlist.Name).
+				WithField("added", len(change.Add)).
+				WithField("removed", len(change.Remove)).
+				Info("Playlist updated")
+		}
+	}
+	return didChange, nil
+}
+
+// shiftPositions adjusts the original positions of a track for the tracks already removed before it, so that
+// restoring removals in reverse order puts every track back where it was.
+func shiftPositions(positions, removed []int) []int {
+	shifted := make([]int, len(positions))
+	for i, position := range positions {
+		shifted[i] = position
+		for _, r := range removed {
+			if r < position {
+				shifted[i]--
+			}
+		}
+	}
+	return shifted
+}
+
+// targetPlaylists returns the playlists a file with the supplied genre should be in, along with the playlists whose
+// membership can't be derived from tags. A playlist is ambiguous when part of its name is erased by TagReplacements
+// or TagRemovals (e.g. "House: No Vocal" with "No Vocal" replaced by ""), as the local tags can't tell it apart.
+// Only the most specific matching playlists are targeted, so "funky house vocal" goes into "House: Funky Vocal" but
+// is not added to "House: Funky" or "House: Vocal". Those less specific playlists are ambiguous too, as the same
+// tags result from a track being in both of them.
+func targetPlaylists(ctx context.Context, genre string, playlists []spotifyapi.SimplePlaylist) (target, ambiguous map[spotifyapi.ID]bool) {
+	removals := removalTags(ctx)
+	local, _ := removeTags(currentTags(replaceTags(ctx, genre)), removals)
+	matching := map[spotifyapi.ID]map[string]bool{}
+	ambiguous = map[spotifyapi.ID]bool{}
+	for _, playlist := range playlists {
+		tags, reversible := reversiblePlaylistTags(ctx, playlist, removals)
+		if !reversible || len(tags) == 0 {
+			ambiguous[playlist.ID] = true
+			continue
+		}
+		if containsTags(local, tags) {
+			matching[playlist.ID] = tags
+		}
+	}
+	target = map[spotifyapi.ID]bool{}
+	for id, tags := range matching {
+		if hasMoreSpecific(tags, matching) {
+			ambiguous[id] = true
+			continue
+		}
+		target[id] = true
+	}
+	return target, ambiguous
+}
+
+// hasMoreSpecific returns true if another tag set in sets contains every tag in tags and more.
+func hasMoreSpecific(tags map[string]bool, sets map[spotifyapi.ID]map[string]bool) bool {
+	for _, other := range sets {
+		if len(other) > len(tags) && containsTags(other, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// reversiblePlaylistTags returns the tags of a playlist, and whether those tags fully describe it.
+func reversiblePlaylistTags(ctx context.Context, playlist spotifyapi.SimplePlaylist, removals map[string]bool) (map[string]bool, bool) {
+	conf := configuration.ContextConfiguration(ctx)
+	for search, replace := range conf.MusicFiles.TagReplacements {
+		if replace == "" && strings.IndexFunc(search, isWordRune) >= 0 && strings.Contains(playlist.Name, search) {
+			return nil, false
+		}
+	}
+	tags, removed := removeTags(playlistTags(ctx, []spotifyapi.SimplePlaylist{playlist}), removals)
+	return tags, len(removed) == 0
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// containsTags returns true if every tag in subset is present in tags.
+func containsTags(tags, subset map[string]bool) bool {
+	for tag := range subset {
+		if !tags[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+// graphPlaylists returns every distinct playlist in the graph, ordered by name.
+func graphPlaylists(graph spotify.TrackGraph) []spotifyapi.SimplePlaylist {
+	lookup := map[spotifyapi.ID]spotifyapi.SimplePlaylist{}
+	for _, playlists := range graph.Playlists {
+		for _, playlist := range playlists {
+			lookup[playlist.ID] = playlist
+		}
+	}
+	playlists := make([]spotifyapi.SimplePlaylist, 0, len(lookup))
+	for _, playlist := range lookup {
+		playlists = append(playlists, playlist)
+	}
+	sort.Slice(playlists, func(i, j int) bool {
+		return playlists[i].Name < playlists[j].Name
+	})
+	return playlists
+}
+
+func playlistAction(kind plan.Kind, key types.SongKey, playlist spotifyapi.SimplePlaylist, trackID spotifyapi.ID) plan.Action {
+	return plan.Action{
+		Kind:   kind,
+		Target: key.Artist + " - " + key.Title,
+		Details: map[string]string{
+			"playlist": playlist.Name,
+			"trackID":  trackID.String(),
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package music
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+func TestTargetPlaylists(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	conf := configuration.ContextConfiguration(ctx)
+	conf.MusicFiles.TagReplacements = map[string]string{
+		"Not House": "NotHouse",
+		"No Vocal":  "",
+		":":         "",
+	}
+	conf.MusicFiles.TagRemovals = []string{"mid"}
+	playlists := []spotifyapi.SimplePlaylist{
+		{ID: "vocal", Name: "House: Vocal"},
+		{ID: "funky", Name: "House: Funky"},
+		{ID: "funkyvocal", Name: "House: Funky Vocal"},
+		{ID: "nothouse", Name: "Not House: Vocal"},
+		{ID: "novocal", Name: "House: No Vocal"},
+		{ID: "mid", Name: "House: Mid"},
+	}
+	for _, test := range []struct {
+		Genre     string
+		Target    []spotifyapi.ID
+		Ambiguous []spotifyapi.ID
+	}{
+		{"house vocal", []spotifyapi.ID{"vocal"}, []spotifyapi.ID{"mid", "novocal"}},
+		{"funky house vocal", []spotifyapi.ID{"funkyvocal"}, []spotifyapi.ID{"funky", "mid", "novocal", "vocal"}},
+		{"funky house", []spotifyapi.ID{"funky"}, []spotifyapi.ID{"mid", "novocal"}},
+		{"house vocal deep", []spotifyapi.ID{"vocal"}, []spotifyapi.ID{"mid", "novocal"}},
+		{"nothouse vocal", []spotifyapi.ID{"nothouse"}, []spotifyapi.ID{"mid", "novocal"}},
+		{"mid", []spotifyapi.ID{}, []spotifyapi.ID{"mid", "novocal"}},
+	} {
+		target, ambiguous := targetPlaylists(ctx, test.Genre, playlists)
+		if got := sortedIDs(target); !reflect.DeepEqual(got, test.Target) {
+			t.Fatalf("%s: Expected target playlists %v but got %v", test.Genre, test.Target, got)
+		}
+		if got := sortedIDs(ambiguous); !reflect.DeepEqual(got, test.Ambiguous) {
+			t.Fatalf("%s: Expected ambiguous playlists %v but got %v", test.Genre, test.Ambiguous, got)
+		}
+	}
+}
+
+func sortedIDs(lookup map[spotifyapi.ID]bool) []spotifyapi.ID {
+	ids := []spotifyapi.ID{}
+	for id := range lookup {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
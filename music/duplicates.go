@@ -0,0 +1,192 @@
+package music
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/mpeg"
+	"github.com/snikch/musicmanager/types"
+)
+
+// Reasons files are grouped as duplicates.
+const (
+	// DuplicateSong files have the same artist and title, so only one of them can be tagged.
+	DuplicateSong = "song"
+	// DuplicateAudio files have the same MPEG audio frames, ignoring their tags.
+	DuplicateAudio = "audio"
+)
+
+// losslessExts are the extensions of files that are always better than a lossy copy.
+var losslessExts = map[string]bool{".flac": true, ".aif": true, ".aiff": true, ".wav": true}
+
+// DuplicateFile is a copy of a duplicated song, with what's known about its audio quality.
+type DuplicateFile struct {
+	File     types.File
+	Size     int64
+	Bitrate  int
+	Duration time.Duration
+	// Hash is the hash of the audio frames of MPEG files that share their audio length with another file.
+	Hash string
+}
+
+// DuplicateGroup is a set of files that are copies of the same song, sorted best first.
+type DuplicateGroup struct {
+	Reasons []string
+	Files   []DuplicateFile
+}
+
+// FindDuplicates groups the files that have the same artist and title, or the same audio. Audio is only hashed for
+// MPEG files whose audio is the same length as another file's, as files of different lengths can't match.
+func FindDuplicates(files []types.File) []DuplicateGroup {
+	dupes := make([]DuplicateFile, len(files))
+	audio := make([]mpeg.Info, len(files))
+	sizes := map[int64]int{}
+	for i, file := range files {
+		dupes[i] = DuplicateFile{File: file, Duration: FileDuration(file)}
+		if stat, err := os.Stat(file.Path()); err == nil {
+			dupes[i].Size = stat.Size()
+		}
+		if strings.ToLower(path.Ext(file.Filename)) != ".mp3" {
+			continue
+		}
+		info, err := mpeg.Probe(file.Path())
+		if err != nil {
+			log.WithError(err).WithField("name", file.Filename).Debug("Could not read mpeg audio info")
+			continue
+		}
+		audio[i] = info
+		dupes[i].Bitrate = info.AverageBitrate
+		dupes[i].Duration = info.Duration
+		sizes[info.AudioSize]++
+	}
+
+	// parents links every file to the first file it duplicates, forming the groups.
+	parents := make([]int, len(files))
+	for i := range parents {
+		parents[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		for parents[i] != i {
+			i = parents[i]
+		}
+		return i
+	}
+	reasons := map[int]map[string]bool{}
+	join := func(a, b int, reason string) {
+		ra, rb := root(a), root(b)
+		if ra > rb {
+			ra, rb = rb, ra
+		}
+		parents[rb] = ra
+		if reasons[ra] == nil {
+			reasons[ra] = map[string]bool{}
+		}
+		reasons[ra][reason] = true
+		for r := range reasons[rb] {
+			reasons[ra][r] = true
+		}
+		if rb != ra {
+			delete(reasons, rb)
+		}
+	}
+	byKey := map[types.SongKey]int{}
+	byHash := map[string]int{}
+	for i := range dupes {
+		if key := fileKey(files[i]); key.Artist != "" && key.Title != "" {
+			if first, ok := byKey[key]; ok {
+				join(first, i, DuplicateSong)
+			} else {
+				byKey[key] = i
+			}
+		}
+		if audio[i].AudioSize == 0 || sizes[audio[i].AudioSize] < 2 {
+			continue
+		}
+		hash, err := hashFile(files[i].Path(), audio[i])
+		if err != nil {
+			log.WithError(err).WithField("name", files[i].Filename).Warn("Could not hash audio")
+			continue
+		}
+		dupes[i].Hash = hash
+		if first, ok := byHash[hash]; ok {
+			join(first, i, DuplicateAudio)
+		} else {
+			byHash[hash] = i
+		}
+	}
+
+	members := map[int][]DuplicateFile{}
+	for i := range dupes {
+		r := root(i)
+		if reasons[r] != nil {
+			members[r] = append(members[r], dupes[i])
+		}
+	}
+	groups := []DuplicateGroup{}
+	for r, files := range members {
+		group := DuplicateGroup{Files: files}
+		for reason := range reasons[r] {
+			group.Reasons = append(group.Reasons, reason)
+		}
+		sort.Strings(group.Reasons)
+		sort.SliceStable(group.Files, func(i, j int) bool {
+			return betterCopy(group.Files[i], group.Files[j])
+		})
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Files[0].File.Path() < groups[j].Files[0].File.Path()
+	})
+	return groups
+}
+
+func hashFile(path string, info mpeg.Info) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return mpeg.HashInfo(file, info)
+}
+
+// betterCopy returns true if a should be kept over b: lossless files first, then the highest bitrate, the longest
+// audio, the largest file and finally the first path. Bitrates are only compared when both are known, as only MP3
+// bitrates are read, so e.g. a 256kbps AAC file isn't ranked below a 128kbps MP3.
+func betterCopy(a, b DuplicateFile) bool {
+	aLossless := losslessExts[strings.ToLower(path.Ext(a.File.Filename))]
+	bLossless := losslessExts[strings.ToLower(path.Ext(b.File.Filename))]
+	switch {
+	case aLossless != bLossless:
+		return aLossless
+	case a.Bitrate != 0 && b.Bitrate != 0 && a.Bitrate != b.Bitrate:
+		return a.Bitrate > b.Bitrate
+	case a.Duration != b.Duration:
+		return a.Duration > b.Duration
+	case a.Size != b.Size:
+		return a.Size > b.Size
+	}
+	return a.File.Path() < b.File.Path()
+}
+
+// KeepBestDuplicates moves every copy but the best of each group into the supplied dir, keeping their file names.
+// The moves are journaled, or recorded on the plan in dry-run mode.
+func KeepBestDuplicates(ctx context.Context, groups []DuplicateGroup, dir string) (int, error) {
+	moved := 0
+	for _, group := range groups {
+		for _, dupe := range group.Files[1:] {
+			_, err := MoveFile(ctx, dupe.File.Path(), filepath.Join(dir, dupe.File.Filename))
+			if err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
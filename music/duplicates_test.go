@@ -0,0 +1,86 @@
+package music
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/types"
+)
+
+// testMPEG returns an ID3v2 tag of the supplied padding followed by frames of silence with the supplied header.
+func testMPEG(tagPadding int, header []byte, frameLength, frames int) []byte {
+	out := append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, byte(tagPadding)}, make([]byte, tagPadding)...)
+	frame := make([]byte, frameLength)
+	copy(frame, header)
+	return append(out, bytes.Repeat(frame, frames)...)
+}
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	standard := []byte{0xFF, 0xFB, 0x90, 0x00}
+	high := []byte{0xFF, 0xFB, 0xE0, 0x00}
+	contents := map[string][]byte{
+		"tagged.mp3":   testMPEG(10, standard, 417, 20),
+		"retagged.mp3": testMPEG(30, standard, 417, 20),
+		"better.mp3":   testMPEG(10, high, 1044, 8),
+		"other.flac":   []byte("fLaC"),
+	}
+	for name, content := range contents {
+		err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []types.File{
+		newMockFile("a", "one", inDir(dir), named("tagged.mp3")),
+		newMockFile("b", "two", inDir(dir), named("retagged.mp3")),
+		newMockFile("a", "one", inDir(dir), named("better.mp3")),
+		newMockFile("c", "three", inDir(dir), named("other.flac")),
+	}
+	groups := FindDuplicates(files)
+	if len(groups) != 1 {
+		t.Fatalf("Expected a single group, got %d", len(groups))
+	}
+	group := groups[0]
+	if len(group.Reasons) != 2 || group.Reasons[0] != DuplicateAudio || group.Reasons[1] != DuplicateSong {
+		t.Fatalf("Expected the group to match by audio and song, got %v", group.Reasons)
+	}
+	names := []string{}
+	for _, dupe := range group.Files {
+		names = append(names, dupe.File.Filename)
+	}
+	if len(names) != 3 || names[0] != "better.mp3" {
+		t.Fatalf("Expected the 320kbps file to be kept first, got %v", names)
+	}
+	if group.Files[0].Bitrate != 320 || group.Files[0].Hash != "" || group.Files[1].Hash == "" {
+		t.Fatalf("Expected only the files with the same audio length to be hashed, got %+v", group.Files)
+	}
+
+	ctx := plan.ContextWithPlan(configuration.ContextWithConfiguration(context.Background()))
+	moved, err := KeepBestDuplicates(ctx, groups, "/rejects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := plan.ContextPlan(ctx).Actions
+	if moved != 2 || len(actions) != 2 || actions[0].Details["to"] != "/rejects/"+names[1] {
+		t.Fatalf("Expected the two worse copies to be moved, got %+v", actions)
+	}
+}
+
+func TestBetterCopyUnknownBitrate(t *testing.T) {
+	mp3 := DuplicateFile{File: newMockFile("a", "one", named("one.mp3")), Bitrate: 128, Duration: 6 * time.Minute, Size: 6000000}
+	m4a := DuplicateFile{File: newMockFile("a", "one", named("one.m4a")), Duration: 6 * time.Minute, Size: 12000000}
+	if !betterCopy(m4a, mp3) || betterCopy(mp3, m4a) {
+		t.Fatal("Expected the larger m4a of unknown bitrate to be kept over the 128kbps mp3")
+	}
+	short := DuplicateFile{File: newMockFile("a", "one", named("short.m4a")), Duration: 3 * time.Minute, Size: 12000000}
+	if !betterCopy(mp3, short) {
+		t.Fatal("Expected the longer mp3 to be kept over a shorter m4a of unknown bitrate")
+	}
+}
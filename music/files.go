@@ -3,108 +3,133 @@ package music
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"path"
 	"strings"
 
-	"github.com/bogem/id3v2"
 	"github.com/everdev/mack"
-	id3 "github.com/mikkyang/id3-go"
 	"github.com/snikch/api/fail"
 	"github.com/snikch/api/log"
 	"github.com/snikch/musicmanager/configuration"
 	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
 	"github.com/snikch/musicmanager/spotify"
-	"github.com/snikch/musicmanager/spotifyclient"
+	"github.com/snikch/musicmanager/state"
 	"github.com/snikch/musicmanager/types"
 )
 
+// GetAllFiles returns every music file in the configured dirs. Tags are read from the library index, and only files
+// that are new or whose size or modification time has changed are parsed.
 func GetAllFiles(ctx context.Context) ([]types.File, error) {
-	files := []types.File{}
+	index, err := LoadIndex()
+	if err != nil {
+		return nil, err
+	}
+	scan := &indexScan{index: index, seen: map[string]bool{}}
 	conf := configuration.ContextConfiguration(ctx)
-	for _, dir := range conf.MusicFiles.Dirs {
-		log.WithField("dir", dir).Info("Loading music files from dir")
-		dirFiles, err := loadDir(dir)
+	log.WithField("dirs", len(conf.MusicFiles.Dirs)).Info("Loading music files")
+	files, err := scan.scan(conf.MusicFiles.Dirs, ScanWorkers(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for loc := range index.Files {
+		if !scan.seen[loc] {
+			delete(index.Files, loc)
+			scan.changed = true
+		}
+	}
+	if scan.changed {
+		err = index.Save()
 		if err != nil {
 			return nil, err
 		}
-		files = append(files, dirFiles...)
 	}
-	log.WithField("total", len(files)).Info("Found local music files")
+	log.WithField("total", len(files)).WithField("parsed", scan.parsed).Info("Found local music files")
 	return files, nil
 }
 
-func loadDir(loc string) ([]types.File, error) {
-	dir, err := ioutil.ReadDir(loc)
+// LoadFile opens the music file with the supplied name in the supplied directory and parses its tags.
+func LoadFile(loc, name string) (types.File, error) {
+	open, ok := formats[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return types.File{}, fail.Trace(fmt.Errorf("unsupported music file %s", name))
+	}
+	song, err := open(loc, name)
 	if err != nil {
-		return nil, err
+		return types.File{}, err
 	}
-	files := make([]types.File, 0, len(dir))
-	log.WithField("dir", loc).WithField("files", len(dir)).Debug("Found files")
-	for _, dirFile := range dir {
-		if dirFile.IsDir() {
-			subFiles, err := loadDir(loc + "/" + dirFile.Name())
-			if err != nil {
-				return nil, err
-			}
-			files = append(files, subFiles...)
-			continue
-		}
-		ext := path.Ext(dirFile.Name())
-		if ext != ".mp3" && ext != ".m4a" {
-			log.WithField("name", dirFile.Name()).Debug("Skipping invalid extension")
-			continue
-		}
-		var song types.Song
-		v2Song, err := id3v2.Open(loc+"/"+dirFile.Name(), id3v2.Options{Parse: true})
-		if err != nil {
-			file, err := id3.Open(loc + "/" + dirFile.Name())
-			if err != nil {
-				return nil, err
-			}
-			comments := file.Comments()
-			if len(comments) > 0 {
-				log.WithField("comments", comments[0]).
-					WithField("len", len(comments)).
-					Warn("Comments")
-			}
-			song = types.ID3Wrapper{file}
-		} else {
-			song = types.ID3V2Wrapper{v2Song}
-		}
-		f := types.File{
-			Song:     types.CleanWrapper{song},
-			Filename: dirFile.Name(),
-			Dir:      loc,
-		}
-
-		log.WithField("name", dirFile.Name()).
-			WithField("title", song.Title()).
-			WithField("comment", f.Comment()).
-			Debug("Found Music Track")
-		files = append(files, f)
+	f := types.File{
+		Song:     types.CleanWrapper{song},
+		Filename: name,
+		Dir:      loc,
 	}
-	return files, nil
+	probeAudio(&f)
+
+	log.WithField("name", name).
+		WithField("title", song.Title()).
+		WithField("comment", f.Comment()).
+		WithField("isrc", f.ISRC()).
+		Debug("Found Music Track")
+	return f, nil
 }
 
+// FilesToFileContexts keys the files by artist and title. Only the last of several files with the same key is kept,
+// so the others are logged to be found with the duplicates command.
 func FilesToFileContexts(ctx context.Context, files []types.File) types.FileContexts {
 	out := types.FileContexts{}
 	for i := range files {
-		out[fileKey(files[i])] = types.FileWithContext{File: files[i]}
+		key := fileKey(files[i])
+		if existing, ok := out[key]; ok {
+			log.WithField("key", key).
+				WithField("kept", files[i].Path()).
+				WithField("skipped", existing.Path()).
+				Warn("Duplicate song, only one file will be tagged")
+		}
+		out[key] = types.FileWithContext{File: files[i]}
 	}
 	return out
 }
 
+// ContextsOfFiles returns a context for every file, in order. FileContexts holds one file per song, so files sharing
+// a song with another file get that file's Spotify and iTunes context, except files with no artist or title, which
+// have nothing in common and get none.
+func ContextsOfFiles(contexts types.FileContexts, files []types.File) []types.FileWithContext {
+	out := make([]types.FileWithContext, 0, len(files))
+	for _, file := range files {
+		key := fileKey(file)
+		fileContext, ok := contexts[key]
+		if !ok || (key.Artist == "" && key.Title == "" && fileContext.Path() != file.Path()) {
+			fileContext = types.FileWithContext{}
+		}
+		fileContext.File = file
+		out = append(out, fileContext)
+	}
+	return out
+}
+
+// HydrateSpotifyOnContexts sets the Spotify track and playlists of every file that matches a track in the graph, by
+// ISRC or by artist and title.
 func HydrateSpotifyOnContexts(ctx context.Context, contexts types.FileContexts, graph spotify.TrackGraph) types.FileContexts {
+	matcher := NewGraphMatcher(graph)
 	for key, fileContext := range contexts {
-		if track, exists := graph.Tracks[key]; exists {
-			log.WithField("key", key).WithField("spotify id", track.ID.String()).Debug("Found track")
-			fileContext.SpotifyTrack = &track
-			fileContext.SpotifyPlaylists = graph.Playlists[key]
-			contexts[key] = fileContext
-		} else {
+		match, ok := matcher.BestFile(fileContext.File)
+		if !ok {
 			log.WithField("key", key).Debug("Couldn't find spotify track")
+			continue
 		}
+		track := graph.Tracks[match.Key]
+		log.WithField("key", key).
+			WithField("match", match.Key).
+			WithField("score", match.Score).
+			WithField("isrc", match.ISRC).
+			WithField("spotify id", track.ID.String()).
+			Debug("Found track")
+		fileContext.SpotifyTrack = &track
+		fileContext.SpotifyKey = match.Key
+		fileContext.SpotifyScore = match.Score
+		fileContext.SpotifyPlaylists = graph.Playlists[match.Key]
+		contexts[key] = fileContext
 	}
 	return contexts
 }
@@ -145,8 +170,16 @@ func fileKey(file types.File) types.SongKey {
 	}
 }
 
+// fileRecordingKey returns the recording of the file, with the mix name parsed from its title.
+func fileRecordingKey(file types.File) types.RecordingKey {
+	return fileKey(file).Recording()
+}
+
+// LeftOuterJoinFilesToGraph returns the tracks in the graph that don't match a local file, by ISRC or by artist and
+// title. Matched tracks are removed from the graph's track lookup.
 func LeftOuterJoinFilesToGraph(ctx context.Context, files []types.File, graph spotify.TrackGraph) spotify.TrackLookup {
 	tracks := graph.Tracks
+	matcher := NewGraphMatcher(graph)
 	for _, file := range files {
 		key := fileKey(file)
 		if key.Artist == "" || key.Title == "" {
@@ -155,9 +188,15 @@ func LeftOuterJoinFilesToGraph(ctx context.Context, files []types.File, graph sp
 				Debug("Skipping unknown track")
 			continue
 		}
-		if track, exists := tracks[key]; exists {
-			log.WithField("key", key).WithField("spotify id", track.ID.String()).Debug("Found track")
-			delete(tracks, key)
+		if match, ok := matcher.BestFile(file); ok {
+			track := tracks[match.Key]
+			log.WithField("key", key).
+				WithField("match", match.Key).
+				WithField("score", match.Score).
+				WithField("isrc", match.ISRC).
+				WithField("spotify id", track.ID.String()).
+				Debug("Found track")
+			delete(tracks, match.Key)
 		} else {
 			log.WithField("key", key).Debug("Couldn't find spotify track for local file")
 		}
@@ -165,13 +204,22 @@ func LeftOuterJoinFilesToGraph(ctx context.Context, files []types.File, graph sp
 	return tracks
 }
 
+// deleteTag returns the genre tag that marks files to be removed.
+func deleteTag(ctx context.Context) string {
+	if tag := configuration.ContextConfiguration(ctx).MusicFiles.DeleteTag; tag != "" {
+		return tag
+	}
+	return "delete"
+}
+
+// RemoveUnwanted removes every file tagged with the delete tag from Spotify playlists and iTunes, and marks its
+// recording as Don't Want so it's never suggested again. It returns true if anything was removed. In dry-run mode
+// the removals are recorded on the plan and false is returned.
 func RemoveUnwanted(ctx context.Context, graph spotify.TrackGraph, contexts types.FileContexts) (bool, error) {
 	didRemove := false
-	client := spotifyclient.ContextClient(ctx)
-	deleteTag := configuration.ContextConfiguration(ctx).MusicFiles.DeleteTag
-	if deleteTag == "" {
-		deleteTag = "delete"
-	}
+	svc := service.ContextService(ctx)
+	p := plan.ContextPlan(ctx)
+	deleteTag := deleteTag(ctx)
 	log.WithField("tag", deleteTag).Info("Starting to remove unwanted tracks")
 	for _, fileContext := range contexts {
 		key := fileKey(fileContext.File)
@@ -182,22 +230,61 @@ func RemoveUnwanted(ctx context.Context, graph spotify.TrackGraph, contexts type
 		}
 
 		l.Warn("Will remove")
+		SetRecordingState(ctx, key.Recording(), state.DontWant)
+		// Missing tracks are excluded by their Spotify key, which may be spelled differently to the file's.
+		if fileContext.SpotifyTrack != nil && fileContext.SpotifyKey.Recording() != key.Recording() {
+			SetRecordingState(ctx, fileContext.SpotifyKey.Recording(), state.DontWant)
+		}
 
 		if fileContext.SpotifyTrack != nil {
 			for _, playlist := range fileContext.SpotifyPlaylists {
+				if p != nil {
+					p.Add(plan.Action{
+						Kind:   plan.KindPlaylistRemove,
+						Target: key.Artist + " - " + key.Title,
+						Details: map[string]string{
+							"playlist": playlist.Name,
+							"trackID":  fileContext.SpotifyTrack.ID.String(),
+						},
+					})
+					continue
+				}
 				didRemove = true
 				l.WithField("playlist", playlist.Name).
 					WithField("trackID", fileContext.SpotifyTrack.ID).
 					WithField("playlistID", playlist.ID).
 					Warn("Removing track from playlist")
-				_, err := client.RemoveTracksFromPlaylist(graph.UserID, playlist.ID, fileContext.SpotifyTrack.ID)
+				positions, err := spotify.PlaylistPositions(ctx, graph.UserID, playlist.ID)
 				if err != nil {
-					return false, fail.Trace(err)
+					return false, err
 				}
+				err = svc.RemovePlaylistTracks(graph.UserID, playlist.ID, fileContext.SpotifyTrack.ID)
+				if err != nil {
+					return false, err
+				}
+				journal.Record(ctx, journal.Entry{
+					Kind:         plan.KindPlaylistRemove,
+					UserID:       graph.UserID,
+					PlaylistID:   string(playlist.ID),
+					PlaylistName: playlist.Name,
+					TrackID:      string(fileContext.SpotifyTrack.ID),
+					Positions:    positions[fileContext.SpotifyTrack.ID],
+				})
 			}
 		}
 
 		if fileContext.ITunesTrack != nil {
+			if p != nil {
+				p.Add(plan.Action{
+					Kind:   plan.KindITunesDelete,
+					Target: key.Artist + " - " + key.Title,
+					Details: map[string]string{
+						"databaseID": fmt.Sprintf("%d", fileContext.ITunesTrack.TrackID),
+						"location":   fileContext.ITunesTrack.Location,
+					},
+				})
+				continue
+			}
 			didRemove = true
 			l.Warn("Removing track from iTunes")
 			// set dbid to database ID of theTrack
@@ -211,6 +298,11 @@ func RemoveUnwanted(ctx context.Context, graph spotify.TrackGraph, contexts type
 			if err != nil {
 				return false, fail.Trace(err)
 			}
+			path, _ := fileContext.ITunesTrack.Path()
+			journal.Record(ctx, journal.Entry{
+				Kind: plan.KindITunesDelete,
+				Path: path,
+			})
 		}
 	}
 	return didRemove, nil
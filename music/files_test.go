@@ -0,0 +1,36 @@
+package music
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/types"
+)
+
+func TestContextsOfFiles(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	files := []types.File{
+		newMockFile("a", "song", named("song.mp3")),
+		newMockFile("a", "song", named("song-copy.mp3")),
+		newMockFile("", "", named("untagged-1.mp3")),
+		newMockFile("", "", named("untagged-2.mp3")),
+	}
+	contexts := FilesToFileContexts(ctx, files)
+	contexts = HydrateITunesOnContexts(ctx, contexts, &itunes.Library{Tracks: map[string]itunes.Track{
+		"1": {TrackID: 1, Artist: "a", Name: "song"},
+	}})
+	listed := ContextsOfFiles(contexts, files)
+	if len(listed) != len(files) {
+		t.Fatalf("Expected a context for each of the %d files, got %d", len(files), len(listed))
+	}
+	for i, fileContext := range listed {
+		if fileContext.Filename != files[i].Filename {
+			t.Fatalf("Expected %s at %d, got %s", files[i].Filename, i, fileContext.Filename)
+		}
+	}
+	if listed[0].ITunesTrack == nil || listed[1].ITunesTrack == nil {
+		t.Fatal("Expected both copies of the song to have its iTunes track")
+	}
+}
@@ -0,0 +1,97 @@
+package music
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/bogem/id3v2"
+	id3 "github.com/mikkyang/id3-go"
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/flac"
+	"github.com/snikch/musicmanager/iff"
+	"github.com/snikch/musicmanager/mp4"
+	"github.com/snikch/musicmanager/types"
+)
+
+// Opener parses the tags of the file with the supplied name in the supplied directory.
+type Opener func(loc, name string) (types.Song, error)
+
+// formats are the openers of every supported file, keyed by lower case extension.
+var formats = map[string]Opener{}
+
+// RegisterFormat makes files with the supplied extension, such as ".mp3", music files that are opened with the
+// supplied opener.
+func RegisterFormat(ext string, open Opener) {
+	formats[strings.ToLower(ext)] = open
+}
+
+func init() {
+	RegisterFormat(".mp3", openID3)
+	RegisterFormat(".m4a", openMP4)
+	RegisterFormat(".flac", openFLAC)
+	RegisterFormat(".aif", openID3Chunk)
+	RegisterFormat(".aiff", openID3Chunk)
+	RegisterFormat(".wav", openID3Chunk)
+}
+
+// IsMusicFile returns true if the file name has an extension that can be loaded.
+func IsMusicFile(name string) bool {
+	_, ok := formats[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// openID3 parses the ID3v2 tag of a file, falling back to an older ID3 parser if it can't be read.
+func openID3(loc, name string) (types.Song, error) {
+	v2Song, err := id3v2.Open(loc+"/"+name, id3v2.Options{Parse: true})
+	if err == nil {
+		return types.ID3V2Wrapper{v2Song}, nil
+	}
+	file, err := id3.Open(loc + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	comments := file.Comments()
+	if len(comments) > 0 {
+		log.WithField("comments", comments[0]).
+			WithField("len", len(comments)).
+			Warn("Comments")
+	}
+	return types.ID3Wrapper{file}, nil
+}
+
+// openMP4 reads the metadata atoms of an MP4 file, such as an AAC file bought from iTunes.
+func openMP4(loc, name string) (types.Song, error) {
+	file, err := mp4.Open(loc + "/" + name)
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	return types.MP4Wrapper{file}, nil
+}
+
+// openFLAC reads the Vorbis comments of a FLAC file.
+func openFLAC(loc, name string) (types.Song, error) {
+	file, err := flac.Open(loc + "/" + name)
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	return types.FLACWrapper{file}, nil
+}
+
+// openID3Chunk parses the ID3 chunk of an AIFF or WAV file. Files without one get an empty tag, which is added to the
+// file when it's saved.
+func openID3Chunk(loc, name string) (types.Song, error) {
+	container, err := iff.Open(loc + "/" + name)
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	tag := id3v2.NewEmptyTag()
+	if container.ID3() != nil {
+		tag, err = id3v2.ParseReader(bytes.NewReader(container.ID3()), id3v2.Options{Parse: true})
+		if err != nil {
+			return nil, fail.Trace(err)
+		}
+	}
+	return types.ID3ChunkWrapper{ID3V2Wrapper: types.ID3V2Wrapper{tag}, Container: container}, nil
+}
@@ -0,0 +1,232 @@
+package music
+
+import (
+	"context"
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/flac"
+	"github.com/snikch/musicmanager/iff"
+	"github.com/snikch/musicmanager/mp4"
+	"github.com/snikch/musicmanager/mpeg"
+	"github.com/snikch/musicmanager/types"
+)
+
+// ImportClass is the outcome of comparing a new file with the library.
+type ImportClass string
+
+const (
+	// ImportNew files have no version in the library.
+	ImportNew ImportClass = "new"
+	// ImportDuplicate files are the same length as a version in the library.
+	ImportDuplicate ImportClass = "duplicate"
+	// ImportLonger files are longer than every version in the library, e.g. an extended mix.
+	ImportLonger ImportClass = "longer"
+	// ImportShorter files are shorter than a version in the library, e.g. a radio edit.
+	ImportShorter ImportClass = "shorter"
+)
+
+// importTolerance is how different two durations can be and still be considered the same recording.
+const importTolerance = 3 * time.Second
+
+// ImportResult is the classification of a single file being imported.
+type ImportResult struct {
+	File     types.File
+	Class    ImportClass
+	Duration time.Duration
+	// Existing is the library file the new file was compared with, if any.
+	Existing         *types.File
+	ExistingDuration time.Duration
+}
+
+// ClassifyDir loads every music file in the directory and classifies it against the library files. Files are
+// compared by artist and title with any mix name removed, so an extended mix is compared with the radio edit. Library
+// files in the directory itself are ignored, as they're the files being imported when the directory is one of the
+// music directories.
+func ClassifyDir(ctx context.Context, dir string, library []types.File) ([]ImportResult, error) {
+	incoming, err := loadDir(dir, ScanWorkers(ctx))
+	if err != nil {
+		return nil, err
+	}
+	outside := make([]types.File, 0, len(library))
+	for _, file := range library {
+		if !underDir(dir, file.Path()) {
+			outside = append(outside, file)
+		}
+	}
+	return classifyFiles(incoming, outside, FileDuration), nil
+}
+
+// underDir returns true if the path is in the directory or one of its subdirectories.
+func underDir(dir, loc string) bool {
+	rel, err := filepath.Rel(dir, loc)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func classifyFiles(incoming, library []types.File, duration func(types.File) time.Duration) []ImportResult {
+	importing := map[string]bool{}
+	for _, file := range incoming {
+		importing[filepath.Clean(file.Path())] = true
+	}
+	lookup := map[types.SongKey][]types.File{}
+	for _, file := range library {
+		if importing[filepath.Clean(file.Path())] {
+			// A file can't be a duplicate of itself.
+			continue
+		}
+		base := fileKey(file).Base()
+		lookup[base] = append(lookup[base], file)
+	}
+	results := make([]ImportResult, 0, len(incoming))
+	for _, file := range incoming {
+		key := fileKey(file)
+		base := key.Base()
+		result := ImportResult{File: file, Class: ImportNew, Duration: duration(file)}
+		l := log.WithField("key", key).WithField("name", file.Filename)
+		if key.Artist == "" || key.Title == "" {
+			l.Warn("Importing file with no artist or title as new")
+			results = append(results, result)
+			continue
+		}
+		var longest, same *types.File
+		var longestDuration, sameDuration time.Duration
+		for i := range lookup[base] {
+			existing := lookup[base][i]
+			existingDuration := duration(existing)
+			if longest == nil || existingDuration > longestDuration {
+				longest = &existing
+				longestDuration = existingDuration
+			}
+			if same == nil && fileKey(existing) == key && sameRecording(result.Duration, existingDuration) {
+				same = &existing
+				sameDuration = existingDuration
+			}
+		}
+		if same != nil {
+			// An exact copy of a version in the library is a duplicate, even if a longer version exists.
+			result.Class = ImportDuplicate
+			result.Existing = same
+			result.ExistingDuration = sameDuration
+		} else if longest != nil {
+			result.Existing = longest
+			result.ExistingDuration = longestDuration
+		}
+		if same == nil && result.Existing != nil && result.Duration > 0 && result.ExistingDuration > 0 {
+			difference := result.Duration - result.ExistingDuration
+			switch {
+			case difference > importTolerance:
+				result.Class = ImportLonger
+			case difference < -importTolerance:
+				result.Class = ImportShorter
+			default:
+				result.Class = ImportDuplicate
+			}
+		}
+		l.WithField("class", result.Class).Debug("Classified file")
+		if result.Class == ImportNew || result.Class == ImportLonger {
+			// Compare later files in the same import with this one too.
+			lookup[base] = append(lookup[base], file)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// sameRecording returns true if two files with the same title are the same length, or either length is unknown.
+func sameRecording(a, b time.Duration) bool {
+	if a == 0 || b == 0 {
+		return true
+	}
+	difference := a - b
+	return difference <= importTolerance && difference >= -importTolerance
+}
+
+// ImportFiles moves new and longer files into the import dir, and duplicates into the rejects dir. Shorter files
+// are left where they are.
+func ImportFiles(ctx context.Context, results []ImportResult) error {
+	conf := configuration.ContextConfiguration(ctx)
+	importDir := conf.MusicFiles.ImportDir
+	if importDir == "" && len(conf.MusicFiles.Dirs) > 0 {
+		importDir = conf.MusicFiles.Dirs[0]
+	}
+	if importDir == "" {
+		return errors.New("no MusicFiles.ImportDir or MusicFiles.Dirs configured to import into")
+	}
+	for _, result := range results {
+		var dir string
+		switch result.Class {
+		case ImportNew, ImportLonger:
+			dir = importDir
+		case ImportDuplicate:
+			dir = conf.MusicFiles.RejectsDir
+			if dir == "" {
+				log.WithField("name", result.File.Filename).Warn("No MusicFiles.RejectsDir configured, leaving duplicate")
+				continue
+			}
+		default:
+			continue
+		}
+		to := filepath.Join(dir, result.File.Filename)
+		if filepath.Clean(result.File.Path()) == filepath.Clean(to) {
+			continue
+		}
+		_, err := MoveFile(ctx, result.File.Path(), to)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileDuration returns the length of the audio in a file, or 0 if it can't be determined.
+func FileDuration(file types.File) time.Duration {
+	if file.Duration == 0 {
+		probeAudio(&file)
+	}
+	return file.Duration
+}
+
+// probeAudio sets the duration, bitrate and sample rate of MP3 files from their frame headers, and the duration of
+// other formats from their container headers.
+func probeAudio(file *types.File) {
+	var container interface {
+		Duration() time.Duration
+	}
+	var err error
+	switch strings.ToLower(path.Ext(file.Filename)) {
+	case ".mp3":
+		probeMPEG(file)
+		return
+	case ".m4a":
+		container, err = mp4.Open(file.Path())
+	case ".flac":
+		container, err = flac.Open(file.Path())
+	case ".aif", ".aiff", ".wav":
+		container, err = iff.Open(file.Path())
+	default:
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("name", file.Filename).Debug("Could not read audio length")
+		return
+	}
+	file.Duration = container.Duration()
+}
+
+// probeMPEG sets the duration, bitrate and sample rate of an MP3 file from its frame headers.
+func probeMPEG(file *types.File) {
+	info, err := mpeg.Probe(file.Path())
+	if err != nil {
+		log.WithError(err).WithField("name", file.Filename).Debug("Could not read mpeg audio info")
+		return
+	}
+	file.Duration = info.Duration
+	file.BitrateMode = string(info.Mode)
+	file.Bitrate = info.AverageBitrate
+	file.SampleRate = info.SampleRate
+}
@@ -0,0 +1,75 @@
+package music
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snikch/musicmanager/types"
+)
+
+func TestClassifyFiles(t *testing.T) {
+	library := []types.File{
+		newMockFile("a", "Radio", named("radio.mp3"), withDuration(3*time.Minute)),
+		newMockFile("c", "Edit", named("edit.mp3"), withDuration(3*time.Minute)),
+		newMockFile("c", "Edit (Extended Mix)", named("edit-extended.mp3"), withDuration(8*time.Minute)),
+		newMockFile("a", "Extended (Extended Mix)", named("extended.mp3"), withDuration(7*time.Minute)),
+		newMockFile("a", "Unknown", named("unknown.m4a")),
+	}
+	incoming := []types.File{
+		newMockFile("b", "Brand New", named("new.mp3"), withDuration(6*time.Minute)),
+		newMockFile("a", "Radio (Extended Mix)", named("radio-extended.mp3"), withDuration(6*time.Minute)),
+		newMockFile("a", "Extended - Radio Edit", named("extended-radio.mp3"), withDuration(3*time.Minute)),
+		newMockFile("a", "Extended", named("extended-copy.mp3"), withDuration(7*time.Minute+time.Second)),
+		newMockFile("a", "Unknown", named("unknown.mp3"), withDuration(5*time.Minute)),
+		newMockFile("b", "Brand New", named("new-copy.mp3"), withDuration(6*time.Minute)),
+		newMockFile("c", "Edit", named("edit-copy.mp3"), withDuration(3*time.Minute+time.Second)),
+	}
+	expected := []ImportClass{ImportNew, ImportLonger, ImportShorter, ImportDuplicate, ImportDuplicate, ImportDuplicate, ImportDuplicate}
+	results := classifyFiles(incoming, library, func(f types.File) time.Duration {
+		return f.Duration
+	})
+	for i, result := range results {
+		if result.Class != expected[i] {
+			t.Fatalf("%s: Expected %s but got %s", result.File.Filename, expected[i], result.Class)
+		}
+	}
+	if existing := results[len(results)-1].Existing; existing == nil || existing.Filename != "edit.mp3" {
+		t.Fatalf("Expected edit-copy.mp3 to be compared with edit.mp3 but got %v", existing)
+	}
+}
+
+func TestClassifyFilesIgnoresItself(t *testing.T) {
+	incoming := []types.File{
+		newMockFile("a", "Song", inDir("/music/downloads"), named("song.mp3"), withDuration(3*time.Minute)),
+		newMockFile("a", "Other", inDir("/music/downloads"), named("other.mp3"), withDuration(5*time.Minute)),
+	}
+	// The downloads are in one of the music directories, so they're in the library too.
+	library := append([]types.File{
+		newMockFile("a", "Other", inDir("/music/house"), named("other.mp3"), withDuration(5*time.Minute)),
+	}, incoming...)
+	results := classifyFiles(incoming, library, func(f types.File) time.Duration {
+		return f.Duration
+	})
+	if results[0].Class != ImportNew {
+		t.Fatalf("Expected a file only in the library as itself to be new, got %s", results[0].Class)
+	}
+	if results[1].Class != ImportDuplicate || results[1].Existing.Dir != "/music/house" {
+		t.Fatalf("Expected a copy of another library file to be a duplicate of it, got %s of %v", results[1].Class, results[1].Existing)
+	}
+}
+
+func TestUnderDir(t *testing.T) {
+	for _, test := range []struct {
+		loc      string
+		expected bool
+	}{
+		{"/music/downloads/song.mp3", true},
+		{"/music/downloads/album/song.mp3", true},
+		{"/music/downloads-old/song.mp3", false},
+		{"/music/song.mp3", false},
+	} {
+		if actual := underDir("/music/downloads", test.loc); actual != test.expected {
+			t.Errorf("underDir(%q): expected %t, got %t", test.loc, test.expected, actual)
+		}
+	}
+}
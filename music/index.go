@@ -0,0 +1,266 @@
+package music
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/types"
+)
+
+const (
+	indexLoc = ".cache/index"
+	// indexVersion is increased whenever the entry format changes, so older indexes are rebuilt.
+	indexVersion = 2
+)
+
+// IndexEntry is the parsed tags of a single file, valid as long as the file's size and modification time are
+// unchanged.
+type IndexEntry struct {
+	Size     int64
+	ModTime  time.Time
+	Key      types.SongKey
+	Comment  string `json:",omitempty"`
+	Genre    string `json:",omitempty"`
+	Year     string `json:",omitempty"`
+	ISRC     string `json:",omitempty"`
+	Duration time.Duration
+	// BitrateMode, Bitrate and SampleRate are only known for MP3 files.
+	BitrateMode string `json:",omitempty"`
+	Bitrate     int    `json:",omitempty"`
+	SampleRate  int    `json:",omitempty"`
+}
+
+// Index caches the tags of every local file, keyed by path, so unchanged files don't need to be parsed.
+type Index struct {
+	Version int
+	Files   map[string]IndexEntry
+}
+
+// LoadIndex reads the index from the cache, returning an empty index if there isn't one or it's out of date.
+func LoadIndex() (*Index, error) {
+	index := &Index{Version: indexVersion, Files: map[string]IndexEntry{}}
+	contents, err := ioutil.ReadFile(indexLoc)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	loaded := &Index{}
+	err = json.Unmarshal(contents, loaded)
+	if err != nil {
+		log.WithError(err).WithField("file", indexLoc).Warn("Ignoring unreadable library index")
+		return index, nil
+	}
+	if loaded.Version != indexVersion || loaded.Files == nil {
+		log.WithField("version", loaded.Version).Info("Ignoring library index from an older version")
+		return index, nil
+	}
+	return loaded, nil
+}
+
+// Save writes the index to the cache.
+func (index *Index) Save() error {
+	contents, err := json.Marshal(index)
+	if err != nil {
+		return fail.Trace(err)
+	}
+	err = os.MkdirAll(filepath.Dir(indexLoc), 0755)
+	if err != nil {
+		return fail.Trace(err)
+	}
+	return fail.Trace(ioutil.WriteFile(indexLoc, contents, 0644))
+}
+
+// file returns the file for an entry. Its tags are read from the entry until one is changed, when the file is
+// parsed so the change can be saved.
+func (entry IndexEntry) file(loc, name string) types.File {
+	return types.File{
+		Song:        &indexedSong{entry: entry, dir: loc, name: name},
+		Filename:    name,
+		Dir:         loc,
+		Duration:    entry.Duration,
+		BitrateMode: entry.BitrateMode,
+		Bitrate:     entry.Bitrate,
+		SampleRate:  entry.SampleRate,
+	}
+}
+
+// newIndexEntry parses a file that isn't in the index, or has changed since it was indexed.
+func newIndexEntry(loc string, info os.FileInfo) (IndexEntry, types.File, error) {
+	file, err := LoadFile(loc, info.Name())
+	if err != nil {
+		return IndexEntry{}, file, err
+	}
+	return IndexEntry{
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Key:         fileKey(file),
+		Comment:     file.Comment(),
+		Genre:       file.Genre(),
+		Year:        file.Year(),
+		ISRC:        file.ISRC(),
+		Duration:    file.Duration,
+		BitrateMode: file.BitrateMode,
+		Bitrate:     file.Bitrate,
+		SampleRate:  file.SampleRate,
+	}, file, nil
+}
+
+// indexScan walks the music dirs, reusing index entries for unchanged files.
+type indexScan struct {
+	index   *Index
+	seen    map[string]bool
+	changed bool
+	parsed  int
+}
+
+// scan returns every music file under the supplied dirs, parsing new and changed files across workers goroutines.
+func (scan *indexScan) scan(dirs []string, workers int) ([]types.File, error) {
+	entries, err := walkDirs(dirs, workers)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]types.File, len(entries))
+	// parsed holds the new index entries, so the index itself is only read while parsing.
+	parsed := make([]*IndexEntry, len(entries))
+	err = parseEntries(entries, workers, func(i int, entry scanEntry) error {
+		name := entry.info.Name()
+		indexed, ok := scan.index.Files[entry.dir+"/"+name]
+		if ok && indexed.Size == entry.info.Size() && indexed.ModTime.Equal(entry.info.ModTime()) {
+			files[i] = indexed.file(entry.dir, name)
+			return nil
+		}
+		indexed, file, err := newIndexEntry(entry.dir, entry.info)
+		if err != nil {
+			return err
+		}
+		files[i] = file
+		parsed[i] = &indexed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		path := entry.dir + "/" + entry.info.Name()
+		scan.seen[path] = true
+		if parsed[i] != nil {
+			scan.index.Files[path] = *parsed[i]
+			scan.changed = true
+			scan.parsed++
+		}
+	}
+	return files, nil
+}
+
+// RebuildIndex discards the library index, so every file is parsed by the next GetAllFiles.
+func RebuildIndex(ctx context.Context) ([]types.File, error) {
+	err := os.Remove(indexLoc)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fail.Trace(err)
+	}
+	return GetAllFiles(ctx)
+}
+
+// indexedSong is a song whose tags come from the index. The file is only opened if a tag is changed or saved.
+type indexedSong struct {
+	entry IndexEntry
+	dir   string
+	name  string
+	song  types.Song
+	err   error
+}
+
+// load parses the file, replacing the indexed tags with the file's.
+func (song *indexedSong) load() types.Song {
+	if song.song == nil && song.err == nil {
+		var file types.File
+		file, song.err = LoadFile(song.dir, song.name)
+		song.song = file.Song
+	}
+	return song.song
+}
+
+func (song *indexedSong) Artist() string {
+	if song.song != nil {
+		return song.song.Artist()
+	}
+	return song.entry.Key.Artist
+}
+
+func (song *indexedSong) Title() string {
+	if song.song != nil {
+		return song.song.Title()
+	}
+	return song.entry.Key.Title
+}
+
+func (song *indexedSong) Comment() string {
+	if song.song != nil {
+		return song.song.Comment()
+	}
+	return song.entry.Comment
+}
+
+func (song *indexedSong) Genre() string {
+	if song.song != nil {
+		return song.song.Genre()
+	}
+	return song.entry.Genre
+}
+
+func (song *indexedSong) Year() string {
+	if song.song != nil {
+		return song.song.Year()
+	}
+	return song.entry.Year
+}
+
+func (song *indexedSong) ISRC() string {
+	if song.song != nil {
+		return song.song.ISRC()
+	}
+	return song.entry.ISRC
+}
+
+func (song *indexedSong) SetComment(comment string) {
+	if s := song.load(); s != nil {
+		s.SetComment(comment)
+	}
+}
+
+func (song *indexedSong) SetGenre(genre string) {
+	if s := song.load(); s != nil {
+		s.SetGenre(genre)
+	}
+}
+
+func (song *indexedSong) SetYear(year string) {
+	if s := song.load(); s != nil {
+		s.SetYear(year)
+	}
+}
+
+func (song *indexedSong) SetISRC(isrc string) {
+	if s := song.load(); s != nil {
+		s.SetISRC(isrc)
+	}
+}
+
+// Save saves the file if a tag has been changed, returning any error opening it.
+func (song *indexedSong) Save() error {
+	if song.err != nil {
+		return song.err
+	}
+	if song.song == nil {
+		return nil
+	}
+	return song.song.Save()
+}
@@ -0,0 +1,61 @@
+package music
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snikch/musicmanager/types"
+)
+
+func TestIndexScanReusesUnchangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The file isn't a valid mp3, so it can only be loaded from the index.
+	loc := filepath.Join(dir, "song.mp3")
+	err = ioutil.WriteFile(loc, []byte("not an mp3"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := &Index{Version: indexVersion, Files: map[string]IndexEntry{
+		dir + "/song.mp3": {
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			Key:      types.SongKey{Artist: "a", Title: "one (Extended Mix)"},
+			Genre:    "house vocal",
+			ISRC:     "GBABC1700001",
+			Duration: 6 * time.Minute,
+		},
+	}}
+	scan := &indexScan{index: index, seen: map[string]bool{}}
+	files, err := scan.scan([]string{dir}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || scan.parsed != 0 || scan.changed {
+		t.Fatalf("Expected the indexed file to be reused, got %d files and %d parsed", len(files), scan.parsed)
+	}
+	file := files[0]
+	if file.Artist() != "a" || file.Title() != "one (Extended Mix)" || file.Genre() != "house vocal" || file.ISRC() != "GBABC1700001" {
+		t.Fatalf("Expected the indexed tags, got %q %q %q %q", file.Artist(), file.Title(), file.Genre(), file.ISRC())
+	}
+	if FileDuration(file) != 6*time.Minute {
+		t.Fatalf("Expected the indexed duration, got %s", FileDuration(file))
+	}
+	if !scan.seen[dir+"/song.mp3"] {
+		t.Fatal("Expected the file to be marked as seen")
+	}
+	// Saving without changing a tag shouldn't open the file.
+	if err := file.Save(); err != nil {
+		t.Fatalf("Expected an unchanged file to save without parsing, got %s", err)
+	}
+}
@@ -0,0 +1,47 @@
+package music
+
+import (
+	"context"
+	"sort"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/types"
+)
+
+// WriteMissingISRCs sets the ISRC of every local file that doesn't have one from its matched Spotify track, so
+// future joins can match it exactly. Only exact matches are written, as a fuzzy match may be a different recording. It returns the number of files updated, or that would be in dry-run mode.
+func WriteMissingISRCs(ctx context.Context, contexts types.FileContexts) (int, error) {
+	keys := make([]types.SongKey, 0, len(contexts))
+	for key := range contexts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return songKeyLess(keys[i], keys[j])
+	})
+	written := 0
+	for _, key := range keys {
+		fileContext := contexts[key]
+		if fileContext.SpotifyTrack == nil || fileContext.ISRC() != "" {
+			continue
+		}
+		if fileContext.SpotifyScore < 1 {
+			log.WithField("key", key).
+				WithField("score", fileContext.SpotifyScore).
+				Debug("Not writing the ISRC of a fuzzy match")
+			continue
+		}
+		isrc := normalizeISRC(fileContext.SpotifyTrack.ExternalIDs["isrc"])
+		if isrc == "" {
+			continue
+		}
+		log.WithField("key", key).WithField("isrc", isrc).Info("Writing ISRC")
+		before := tagValues(fileContext)
+		fileContext.SetISRC(isrc)
+		err := saveTags(ctx, fileContext.File, tagChanges(before, tagValues(fileContext)))
+		if err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
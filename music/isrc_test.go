@@ -0,0 +1,43 @@
+package music
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/types"
+	"github.com/zmb3/spotify"
+)
+
+func TestWriteMissingISRCs(t *testing.T) {
+	ctx := plan.ContextWithPlan(configuration.ContextWithConfiguration(context.Background()))
+	missing := newMockFile("a", "one")
+	tagged := newMockFile("b", "two")
+	tagged.SetISRC("GBABC1700002")
+	unmatched := newMockFile("c", "three")
+	fuzzy := newMockFile("d", "four (Dub)")
+	contexts := types.FileContexts{
+		{Artist: "a", Title: "one"}:        {File: missing, SpotifyScore: 1, SpotifyTrack: &spotify.FullTrack{ExternalIDs: map[string]string{"isrc": "gb-abc-17-00001"}}},
+		{Artist: "b", Title: "two"}:        {File: tagged, SpotifyScore: 1, SpotifyTrack: &spotify.FullTrack{ExternalIDs: map[string]string{"isrc": "GBABC1700003"}}},
+		{Artist: "c", Title: "three"}:      {File: unmatched},
+		{Artist: "d", Title: "four (Dub)"}: {File: fuzzy, SpotifyScore: 0.95, SpotifyTrack: &spotify.FullTrack{ExternalIDs: map[string]string{"isrc": "GBABC1700004"}}},
+	}
+	written, err := WriteMissingISRCs(ctx, contexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 || missing.ISRC() != "GBABC1700001" {
+		t.Fatalf("Expected only the untagged file to get an ISRC, wrote %d and got %q", written, missing.ISRC())
+	}
+	if fuzzy.ISRC() != "" {
+		t.Fatalf("Expected no ISRC from a fuzzy match, got %q", fuzzy.ISRC())
+	}
+	if tagged.ISRC() != "GBABC1700002" {
+		t.Fatalf("Expected the existing ISRC to be kept, got %q", tagged.ISRC())
+	}
+	actions := plan.ContextPlan(ctx).Actions
+	if len(actions) != 1 || actions[0].Changes[0] != (plan.Change{Field: "ISRC", New: "GBABC1700001"}) {
+		t.Fatalf("Expected a single ISRC tag write in the plan, got %+v", actions)
+	}
+}
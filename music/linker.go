@@ -0,0 +1,96 @@
+package music
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+// CatalogLoc is where the linked catalog is persisted.
+const CatalogLoc = ".cache/catalog"
+
+// LinkCatalog groups local files, Spotify playlist tracks and iTunes tracks into songs and their recordings, so that
+// e.g. a local extended mix, the radio edit in a Spotify playlist and the iTunes copy are all the same song.
+func LinkCatalog(ctx context.Context, files []types.File, graph spotify.TrackGraph, library *itunes.Library) *types.Catalog {
+	catalog := types.NewCatalog()
+	albums := map[types.SongKey]string{}
+	if library != nil {
+		for _, track := range library.Tracks {
+			catalog.Add(types.RecordingKeyForTitle(track.Artist, track.Name), types.ServiceTrack{
+				Service: types.ServiceITunes,
+				ID:      strconv.Itoa(track.TrackID),
+				Title:   track.Name,
+				Album:   track.Album,
+				Year:    track.Year,
+				Artists: []string{track.Artist},
+			})
+			albums[types.SongKey{Artist: track.Artist, Title: track.Name}] = track.Album
+		}
+	}
+	for key, track := range graph.Tracks {
+		artists := make([]string, len(track.Artists))
+		for i := range track.Artists {
+			artists[i] = track.Artists[i].Name
+		}
+		recordingKey := graph.Recording(key)
+		catalog.Add(recordingKey, types.ServiceTrack{
+			Service: types.ServiceSpotify,
+			ID:      track.ID.String(),
+			Title:   track.Name,
+			Album:   track.Album.Name,
+			Artists: artists,
+		})
+		composition := catalog.Composition(recordingKey.Song)
+		for _, playlist := range graph.Playlists[key] {
+			composition.AddPlaylist(playlist.Name)
+		}
+	}
+	for _, file := range files {
+		key := fileKey(file)
+		if key.Artist == "" || key.Title == "" {
+			continue
+		}
+		year, _ := strconv.Atoi(file.Year())
+		catalog.Add(fileRecordingKey(file), types.ServiceTrack{
+			Service: types.ServiceLocal,
+			ID:      file.Path(),
+			Title:   key.Title,
+			// Local tags don't expose the album, so use the album of the iTunes copy if there is one.
+			Album:   albums[key],
+			Year:    year,
+			Artists: []string{key.Artist},
+		})
+	}
+	return catalog
+}
+
+// HydrateCatalogOnContexts sets the playlists of every other recording of each file's song, so that playlist tags
+// apply to the song rather than to the one file that matched the playlist track.
+func HydrateCatalogOnContexts(ctx context.Context, contexts types.FileContexts, catalog *types.Catalog, graph spotify.TrackGraph) types.FileContexts {
+	lookup := map[string]spotifyapi.SimplePlaylist{}
+	for _, playlist := range graphPlaylists(graph) {
+		lookup[playlist.Name] = playlist
+	}
+	for key, fileContext := range contexts {
+		composition := catalog.Composition(key)
+		if composition == nil {
+			continue
+		}
+		own := map[string]bool{}
+		for _, playlist := range fileContext.SpotifyPlaylists {
+			own[playlist.Name] = true
+		}
+		fileContext.SongPlaylists = nil
+		for _, name := range composition.Playlists {
+			if playlist, ok := lookup[name]; ok && !own[name] {
+				fileContext.SongPlaylists = append(fileContext.SongPlaylists, playlist)
+			}
+		}
+		contexts[key] = fileContext
+	}
+	return contexts
+}
@@ -0,0 +1,61 @@
+package music
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+func TestLinkCatalog(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	files := []types.File{newMockFile("a", "one (Extended Mix)")}
+	radioEdit := types.SongKey{Artist: "a", Title: "one (Radio Edit)"}
+	vocal := spotifyapi.SimplePlaylist{ID: "1", Name: "House: Vocal"}
+	graph := spotify.TrackGraph{
+		Tracks:    spotify.TrackLookup{radioEdit: {}},
+		Playlists: spotify.PlaylistLookup{radioEdit: {vocal}},
+	}
+	library := &itunes.Library{Tracks: map[string]itunes.Track{
+		"1": {TrackID: 1, Artist: "a", Name: "one (Extended Mix)", Album: "one EP"},
+	}}
+
+	catalog := LinkCatalog(ctx, files, graph, library)
+	composition := catalog.Composition(types.SongKey{Artist: "a", Title: "one"})
+	if composition == nil {
+		t.Fatalf("Expected a single song, got %+v", catalog.Compositions)
+	}
+	if len(catalog.Compositions) != 1 || len(composition.Recordings) != 2 {
+		t.Fatalf("Expected 1 song with 2 recordings, got %d songs and %+v", len(catalog.Compositions), composition.Recordings)
+	}
+	extended := composition.Recordings[string(types.RecordingExtended)]
+	if extended == nil || len(extended.Tracks) != 1 || len(extended.Tracks[0].Instances) != 2 {
+		t.Fatalf("Expected the local file and iTunes track to be one release, got %+v", extended)
+	}
+
+	contexts := HydrateCatalogOnContexts(ctx, FilesToFileContexts(ctx, files), catalog, graph)
+	for _, fileContext := range contexts {
+		if len(fileContext.SongPlaylists) != 1 || fileContext.SongPlaylists[0].Name != vocal.Name {
+			t.Fatalf("Expected the radio edit's playlist on the extended mix, got %+v", fileContext.SongPlaylists)
+		}
+	}
+}
+
+func TestLinkCatalogNormalizesArtists(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	files := []types.File{newMockFile("A & B", "One (Extended Mix)")}
+	radioEdit := types.SongKey{Artist: "A, B", Title: "One (Radio Edit)"}
+	graph := spotify.TrackGraph{Tracks: spotify.TrackLookup{radioEdit: {}}}
+
+	catalog := LinkCatalog(ctx, files, graph, nil)
+	if len(catalog.Compositions) != 1 {
+		t.Fatalf("Expected \"A & B\" and \"A, B\" to be one song, got %+v", catalog.Compositions)
+	}
+	if composition := catalog.Composition(types.SongKey{Artist: "b & a", Title: "one"}); composition == nil {
+		t.Fatalf("Expected to find the song by its normalized key, got %+v", catalog.Compositions)
+	}
+}
@@ -0,0 +1,120 @@
+package music
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+)
+
+const (
+	// MatchThreshold is the score a Spotify track needs to be matched to a local file.
+	MatchThreshold = 0.9
+	// NearMissThreshold is the score above which an unmatched candidate is reported as a near miss.
+	NearMissThreshold = 0.6
+)
+
+// Match is the best candidate for a key and how confident the match is.
+type Match struct {
+	Key   types.SongKey
+	Score float64
+	// ISRC is true if the match was made by ISRC rather than artist and title.
+	ISRC bool
+}
+
+// Matcher finds the best matching key for local songs, tolerating differences in case, accents, artist order and
+// separators, featured artists and "(Original Mix)".
+type Matcher struct {
+	keys       []types.SongKey
+	normalized []types.NormalizedKey
+	exact      map[types.SongKey]int
+	// candidates indexes keys by their normalized title and each of their artists.
+	candidates map[string][]int
+	isrcs      map[string]types.SongKey
+}
+
+// NewMatcher returns a matcher of the supplied keys.
+func NewMatcher(keys []types.SongKey) *Matcher {
+	keys = append([]types.SongKey{}, keys...)
+	sort.Slice(keys, func(i, j int) bool {
+		return songKeyLess(keys[i], keys[j])
+	})
+	matcher := &Matcher{
+		keys:       keys,
+		normalized: make([]types.NormalizedKey, len(keys)),
+		exact:      map[types.SongKey]int{},
+		candidates: map[string][]int{},
+		isrcs:      map[string]types.SongKey{},
+	}
+	for i, key := range keys {
+		normalized := key.Normalize()
+		matcher.normalized[i] = normalized
+		matcher.exact[key] = i
+		matcher.candidates["title:"+normalized.Title] = append(matcher.candidates["title:"+normalized.Title], i)
+		for _, artist := range normalized.Artists {
+			matcher.candidates["artist:"+artist] = append(matcher.candidates["artist:"+artist], i)
+		}
+	}
+	return matcher
+}
+
+// NewGraphMatcher returns a matcher of every track in the graph, which can also match by the tracks' ISRCs. If
+// several tracks share an ISRC, such as the same recording on different releases, the first by key is matched.
+func NewGraphMatcher(graph spotify.TrackGraph) *Matcher {
+	keys := make([]types.SongKey, 0, len(graph.Tracks))
+	for key := range graph.Tracks {
+		keys = append(keys, key)
+	}
+	matcher := NewMatcher(keys)
+	for _, key := range matcher.keys {
+		isrc := normalizeISRC(graph.Tracks[key].ExternalIDs["isrc"])
+		if _, ok := matcher.isrcs[isrc]; isrc != "" && !ok {
+			matcher.isrcs[isrc] = key
+		}
+	}
+	return matcher
+}
+
+// BestFile returns the best match for a local file, matching by ISRC first and then by artist and title.
+func (matcher *Matcher) BestFile(file types.File) (Match, bool) {
+	if isrc := normalizeISRC(file.ISRC()); isrc != "" {
+		if key, ok := matcher.isrcs[isrc]; ok {
+			return Match{Key: key, Score: 1, ISRC: true}, true
+		}
+	}
+	return matcher.Best(fileKey(file))
+}
+
+// normalizeISRC uppercases an ISRC and removes the hyphens and spaces it's sometimes written with.
+func normalizeISRC(isrc string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isrc)))
+}
+
+// Best returns the highest scoring candidate for the key, and whether it scores high enough to be a match. Keys that
+// share no title or artist with the key aren't considered.
+func (matcher *Matcher) Best(key types.SongKey) (Match, bool) {
+	if _, ok := matcher.exact[key]; ok {
+		return Match{Key: key, Score: 1}, true
+	}
+	normalized := key.Normalize()
+	seen := map[int]bool{}
+	best := Match{}
+	consider := func(indexes []int) {
+		for _, i := range indexes {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			score := types.MatchScore(normalized, matcher.normalized[i])
+			if score > best.Score {
+				best = Match{Key: matcher.keys[i], Score: score}
+			}
+		}
+	}
+	consider(matcher.candidates["title:"+normalized.Title])
+	for _, artist := range normalized.Artists {
+		consider(matcher.candidates["artist:"+artist])
+	}
+	return best, best.Score >= MatchThreshold
+}
@@ -0,0 +1,45 @@
+package music
+
+import (
+	"testing"
+
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+)
+
+func TestMatcherBest(t *testing.T) {
+	matcher := NewMatcher([]types.SongKey{
+		{Artist: "Disclosure, Sam Smith", Title: "Latch"},
+		{Artist: "Disclosure", Title: "Latch - Extended Mix"},
+		{Artist: "Bicep", Title: "Glue"},
+	})
+	tests := []struct {
+		key      types.SongKey
+		expected types.SongKey
+		ok       bool
+	}{
+		{types.SongKey{Artist: "Bicep", Title: "Glue"}, types.SongKey{Artist: "Bicep", Title: "Glue"}, true},
+		{types.SongKey{Artist: "Disclosure", Title: "Latch (feat. Sam Smith) (Original Mix)"}, types.SongKey{Artist: "Disclosure, Sam Smith", Title: "Latch"}, true},
+		{types.SongKey{Artist: "DISCLOSURE", Title: "Latch (Extended Mix)"}, types.SongKey{Artist: "Disclosure", Title: "Latch - Extended Mix"}, true},
+		{types.SongKey{Artist: "Bicep", Title: "Glue (Radio Edit)"}, types.SongKey{Artist: "Bicep", Title: "Glue"}, false},
+	}
+	for _, test := range tests {
+		match, ok := matcher.Best(test.key)
+		if match.Key != test.expected || ok != test.ok {
+			t.Errorf("Best(%+v): expected %+v (%t), got %+v (%t)", test.key, test.expected, test.ok, match, ok)
+		}
+	}
+}
+
+func TestMatcherBestFileByISRC(t *testing.T) {
+	key := types.SongKey{Artist: "Bicep", Title: "Glue"}
+	graph := spotify.TrackGraph{Tracks: spotify.TrackLookup{
+		key: {ExternalIDs: map[string]string{"isrc": "GB-ABC-17-00001"}},
+	}}
+	file := newMockFile("Bicep", "Glue (Original Mix) [Label]")
+	file.SetISRC("gbabc1700001")
+	match, ok := NewGraphMatcher(graph).BestFile(file)
+	if !ok || !match.ISRC || match.Key != key {
+		t.Fatalf("Expected an ISRC match of %+v, got %+v (%t)", key, match, ok)
+	}
+}
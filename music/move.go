@@ -0,0 +1,90 @@
+package music
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+)
+
+// MoveFile moves the file at src to dst, creating any missing directories. If a different file already exists at dst
+// a number is added to the name, e.g. "Song (1).mp3". It returns the path the file was moved to. In dry-run mode the
+// move is recorded on the plan instead.
+func MoveFile(ctx context.Context, src, dst string) (string, error) {
+	dst = availablePath(dst)
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(plan.Action{
+			Kind:    plan.KindFileMove,
+			Target:  src,
+			Details: map[string]string{"to": dst},
+		})
+		return dst, nil
+	}
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return "", fail.Trace(err)
+	}
+	log.WithField("from", src).WithField("to", dst).Info("Moving file")
+	err = os.Rename(src, dst)
+	if err != nil {
+		// Renames fail across devices, so fall back to copying the file.
+		log.WithError(err).WithField("from", src).Debug("Rename failed, copying file")
+		err = copyFile(src, dst)
+		if err != nil {
+			return "", err
+		}
+		err = os.Remove(src)
+		if err != nil {
+			return "", fail.Trace(err)
+		}
+	}
+	journal.Record(ctx, journal.Entry{
+		Kind: plan.KindFileMove,
+		Path: src,
+		To:   dst,
+	})
+	return dst, nil
+}
+
+// availablePath returns the supplied path, or the first numbered variant of it that doesn't exist yet.
+func availablePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fail.Trace(err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return fail.Trace(err)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode())
+	if err != nil {
+		return fail.Trace(err)
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fail.Trace(err)
+	}
+	return fail.Trace(out.Close())
+}
@@ -0,0 +1,284 @@
+package music
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/everdev/mack"
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/types"
+)
+
+// DefaultOrganizeTemplate files songs by their first genre tag and artist.
+const DefaultOrganizeTemplate = "{genre0}/{artist}/{artist} - {title} ({mix}).{ext}"
+
+// maxSegmentLength is the longest file or directory name written, leaving room for a collision number.
+const maxSegmentLength = 200
+
+var (
+	templateField = regexp.MustCompile(`\{([a-z]+)(\d*)\}`)
+	emptyBrackets = regexp.MustCompile(`\(\s*\)|\[\s*\]`)
+	spaces        = regexp.MustCompile(`\s+`)
+	// illegalChars can't be used in file names on macOS or Windows, or would start a new directory.
+	illegalChars = strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "", "?", "", "\"", "'", "<", "", ">", "", "|", "-")
+)
+
+// ITunesMode is what Organize does about files in the iTunes library, which iTunes loses track of when they move.
+type ITunesMode int
+
+const (
+	// ITunesRefuse moves nothing if any file to be moved is in the library, returning ErrITunesTracks.
+	ITunesRefuse ITunesMode = iota
+	// ITunesRelocate points the iTunes track of each moved file at its new location.
+	ITunesRelocate
+	// ITunesIgnore moves files without updating iTunes, e.g. when the moves are written to a relocation map.
+	ITunesIgnore
+)
+
+// ErrITunesTracks is returned by Organize when files in the iTunes library would be moved without relocating them.
+var ErrITunesTracks = errors.New("files to be moved are in the iTunes library, which would lose track of them")
+
+// Relocation is a file that was moved by Organize.
+type Relocation struct {
+	From string
+	To   string
+	// ITunesTrack is the iTunes track of the file, if it's in the library.
+	ITunesTrack *itunes.Track
+}
+
+// OrganizeDir returns the directory files are organized into.
+func OrganizeDir(ctx context.Context) (string, error) {
+	conf := configuration.ContextConfiguration(ctx)
+	switch {
+	case conf.MusicFiles.Organize.Dir != "":
+		return conf.MusicFiles.Organize.Dir, nil
+	case conf.MusicFiles.ImportDir != "":
+		return conf.MusicFiles.ImportDir, nil
+	case len(conf.MusicFiles.Dirs) > 0:
+		return conf.MusicFiles.Dirs[0], nil
+	}
+	return "", errors.New("no MusicFiles.Organize.Dir, MusicFiles.ImportDir or MusicFiles.Dirs configured to organize into")
+}
+
+// OrganizePath returns the path of the file relative to the organize dir, from a template of fields in braces:
+//
+//   - {artist}, {title} without any mix name, {fulltitle} and {mix}, e.g. "Extended Mix"
+//   - {genre} and {genre0}, {genre1} etc. for a single genre tag, skipping the delete and low bitrate tags
+//   - {year}, {isrc} and {ext}, the extension without a dot
+//
+// Characters that can't be used in file names are replaced, and empty brackets left by missing fields are removed.
+// Empty directory names become "Unknown".
+func OrganizePath(ctx context.Context, template string, file types.File) (string, error) {
+	parsed := types.ParseTitle(file.Title())
+	lowBitrateTag, _ := LowBitrateThreshold(ctx)
+	skip := map[string]bool{strings.ToLower(deleteTag(ctx)): true, lowBitrateTag: true}
+	genres := []string{}
+	for _, genre := range strings.Fields(file.Genre()) {
+		if !skip[strings.ToLower(genre)] {
+			genres = append(genres, genre)
+		}
+	}
+	var err error
+	rendered := templateField.ReplaceAllStringFunc(template, func(field string) string {
+		match := templateField.FindStringSubmatch(field)
+		name, index := match[1], match[2]
+		if index != "" && name != "genre" {
+			err = fmt.Errorf("organize template field %s can't have an index", field)
+			return ""
+		}
+		value := ""
+		switch name {
+		case "artist":
+			value = file.Artist()
+		case "title":
+			value = parsed.Base
+		case "fulltitle":
+			value = file.Title()
+		case "mix":
+			value = parsed.MixName()
+		case "genre":
+			value = file.Genre()
+			if index != "" {
+				i, _ := strconv.Atoi(index)
+				value = ""
+				if i < len(genres) {
+					value = genres[i]
+				}
+			}
+		case "year":
+			value = file.Year()
+		case "isrc":
+			value = file.ISRC()
+		case "ext":
+			value = strings.ToLower(strings.TrimPrefix(path.Ext(file.Filename), "."))
+		default:
+			err = fmt.Errorf("unknown organize template field %s", field)
+		}
+		return strings.TrimSpace(illegalChars.Replace(value))
+	})
+	if err != nil {
+		return "", err
+	}
+	segments := strings.Split(rendered, "/")
+	for i, segment := range segments {
+		segments[i] = cleanSegment(segment, i == len(segments)-1)
+	}
+	return filepath.Join(segments...), nil
+}
+
+// cleanSegment tidies a single file or directory name, keeping the extension of the file name when it's shortened.
+func cleanSegment(segment string, last bool) string {
+	segment = emptyBrackets.ReplaceAllString(segment, "")
+	segment = spaces.ReplaceAllString(segment, " ")
+	segment = strings.Replace(segment, " .", ".", -1)
+	segment = strings.TrimLeft(segment, ". ")
+	segment = strings.TrimRight(segment, ". -")
+	if segment == "" {
+		return "Unknown"
+	}
+	ext := ""
+	if last {
+		ext = path.Ext(segment)
+	}
+	base := strings.TrimSuffix(segment, ext)
+	for len(base)+len(ext) > maxSegmentLength {
+		// Trim whole runes so the name stays valid UTF-8.
+		base = strings.TrimRight(string([]rune(base)[:len([]rune(base))-1]), ". -")
+	}
+	return base + ext
+}
+
+// Organize moves every file to its path from the template, relative to the supplied dir. Files without an artist or
+// title are left alone. When two files would end up at the same path, or a different file is already there, a number
+// is added to the name. Moved files are reloaded from their new location, and their iTunes tracks are handled as the
+// mode says. In dry-run mode the moves are recorded on the plan instead.
+func Organize(ctx context.Context, files []types.File, dir, template string, library *itunes.Library, mode ITunesMode) ([]Relocation, error) {
+	tracks := map[string]*itunes.Track{}
+	if library != nil {
+		for key := range library.Tracks {
+			track := library.Tracks[key]
+			if loc, err := track.Path(); err == nil {
+				tracks[loc] = &track
+			}
+		}
+	}
+	// Work out every move first, so nothing is moved if the iTunes tracks can't be handled.
+	moves := []Relocation{}
+	indexes := []int{}
+	// claimed are the lower cased paths files are moving to, as macOS file names aren't case sensitive.
+	claimed := map[string]bool{}
+	inITunes := 0
+	for i, file := range files {
+		key := fileKey(file)
+		if key.Artist == "" || key.Title == "" {
+			log.WithField("name", file.Filename).Warn("Not organizing file with no artist or title")
+			continue
+		}
+		rel, err := OrganizePath(ctx, template, file)
+		if err != nil {
+			return nil, err
+		}
+		from := filepath.Clean(file.Path())
+		target := claimPath(filepath.Join(dir, rel), from, claimed)
+		claimed[strings.ToLower(target)] = true
+		if target == from {
+			continue
+		}
+		if tracks[from] != nil {
+			inITunes++
+		}
+		moves = append(moves, Relocation{From: from, To: target, ITunesTrack: tracks[from]})
+		indexes = append(indexes, i)
+	}
+	if mode == ITunesRefuse && inITunes > 0 {
+		log.WithField("files", inITunes).Error("Not moving files that are in the iTunes library")
+		return nil, ErrITunesTracks
+	}
+
+	relocations := []Relocation{}
+	for n, relocation := range moves {
+		to, err := MoveFile(ctx, relocation.From, relocation.To)
+		if err != nil {
+			return relocations, err
+		}
+		relocation.To = to
+		relocations = append(relocations, relocation)
+		if mode == ITunesRelocate && relocation.ITunesTrack != nil {
+			err = relocateITunesTrack(ctx, relocation.ITunesTrack.TrackID, relocation.From, to)
+			if err != nil {
+				return relocations, err
+			}
+		}
+		if plan.DryRun(ctx) {
+			continue
+		}
+		files[indexes[n]], err = LoadFile(filepath.Dir(to), filepath.Base(to))
+		if err != nil {
+			return relocations, err
+		}
+	}
+	return relocations, nil
+}
+
+// claimPath returns the supplied path, or the first numbered variant of it that hasn't been claimed by another file
+// in the same run and either doesn't exist or is the file's current path, so organizing again doesn't move files that
+// were numbered last time.
+func claimPath(target, from string, claimed map[string]bool) string {
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	candidate := target
+	for i := 1; ; i++ {
+		if !claimed[strings.ToLower(candidate)] {
+			if candidate == from {
+				return candidate
+			}
+			if _, err := os.Stat(candidate); os.IsNotExist(err) {
+				return candidate
+			}
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+// relocateITunesTrack points an iTunes track at its file's new location, journaling the change. In dry-run mode the
+// change is recorded on the plan instead.
+func relocateITunesTrack(ctx context.Context, trackID int, from, to string) error {
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(plan.Action{
+			Kind:   plan.KindITunesRelocate,
+			Target: from,
+			Details: map[string]string{
+				"databaseID": strconv.Itoa(trackID),
+				"to":         to,
+			},
+		})
+		return nil
+	}
+	log.WithField("databaseID", trackID).WithField("to", to).Info("Relocating iTunes track")
+	_, err := mack.Tell("iTunes", fmt.Sprintf(`
+		set theTrack to (some track of playlist "Library" whose database ID is %d)
+		set location of theTrack to POSIX file %q
+		`, trackID, to))
+	if err != nil {
+		return fail.Trace(err)
+	}
+	journal.Record(ctx, journal.Entry{
+		Kind:          plan.KindITunesRelocate,
+		Path:          from,
+		To:            to,
+		ITunesTrackID: trackID,
+	})
+	return nil
+}
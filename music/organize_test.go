@@ -0,0 +1,140 @@
+package music
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/types"
+)
+
+func TestOrganizePath(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	for _, test := range []struct {
+		template string
+		file     types.File
+		expected string
+	}{
+		{DefaultOrganizeTemplate, newMockFile("Someone", "Song (Extended Mix)", withGenre("house vocal"), named("x.MP3")), "house/Someone/Someone - Song (Extended Mix).mp3"},
+		{DefaultOrganizeTemplate, newMockFile("Someone", "Song", withGenre("house"), named("x.mp3")), "house/Someone/Someone - Song.mp3"},
+		{DefaultOrganizeTemplate, newMockFile("Someone", "Song", named("x.mp3")), "Unknown/Someone/Someone - Song.mp3"},
+		{DefaultOrganizeTemplate, newMockFile("AC/DC: Live?", "Song", withGenre("rock"), named("x.flac")), "rock/AC-DC- Live/AC-DC- Live - Song.flac"},
+		{"{genre1}/{fulltitle} [{year}].{ext}", newMockFile("Someone", "Song (Radio Edit)", withGenre("house vocal"), named("x.m4a")), "vocal/Song (Radio Edit).m4a"},
+		{DefaultOrganizeTemplate, newMockFile("Someone", "Song", withGenre("delete house lowbitrate"), named("x.mp3")), "house/Someone/Someone - Song.mp3"},
+		{"{genre}/..{title}", newMockFile("Someone", "Song", withGenre("house vocal"), named("x.mp3")), "house vocal/Song"},
+	} {
+		path, err := OrganizePath(ctx, test.template, test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != test.expected {
+			t.Fatalf("Expected %q from %q, got %q", test.expected, test.template, path)
+		}
+	}
+	for _, template := range []string{"{album}/{title}", "{artist1}/{title}"} {
+		if _, err := OrganizePath(ctx, template, newMockFile("Someone", "Song", named("x.mp3"))); err == nil {
+			t.Fatalf("Expected %q to be invalid", template)
+		}
+	}
+}
+
+func TestOrganizeCollisions(t *testing.T) {
+	ctx := plan.ContextWithPlan(configuration.ContextWithConfiguration(context.Background()))
+	dir := t.TempDir()
+	files := []types.File{
+		newMockFile("Someone", "Song", withGenre("house"), inDir("/downloads"), named("1.mp3")),
+		newMockFile("Someone", "Song", withGenre("house"), inDir("/downloads"), named("2.mp3")),
+		newMockFile("", "Unknown", withGenre("house"), inDir("/downloads"), named("3.mp3")),
+	}
+	library := &itunes.Library{Tracks: map[string]itunes.Track{
+		"42": {TrackID: 42, Location: "file:///downloads/2.mp3"},
+	}}
+	relocations, err := Organize(ctx, files, dir, "{artist} - {title}.{ext}", library, ITunesRelocate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relocations) != 2 {
+		t.Fatalf("Expected the two tagged files to be moved, got %+v", relocations)
+	}
+	if relocations[0].To != filepath.Join(dir, "Someone - Song.mp3") || relocations[1].To != filepath.Join(dir, "Someone - Song (1).mp3") {
+		t.Fatalf("Expected the second file to be numbered, got %q and %q", relocations[0].To, relocations[1].To)
+	}
+	if relocations[0].ITunesTrack != nil || relocations[1].ITunesTrack == nil || relocations[1].ITunesTrack.TrackID != 42 {
+		t.Fatalf("Expected only the second file to have an iTunes track, got %+v", relocations)
+	}
+	actions := plan.ContextPlan(ctx).Actions
+	if len(actions) != 3 || actions[2].Kind != plan.KindITunesRelocate || actions[2].Details["to"] != relocations[1].To {
+		t.Fatalf("Expected two moves and an iTunes relocation, got %+v", actions)
+	}
+	if files[0].Dir != "/downloads" {
+		t.Fatal("Expected files not to be changed in a dry run")
+	}
+}
+
+func TestOrganizeRefusesITunesTracks(t *testing.T) {
+	ctx := plan.ContextWithPlan(configuration.ContextWithConfiguration(context.Background()))
+	dir := t.TempDir()
+	files := []types.File{
+		newMockFile("Someone", "Song", inDir("/downloads"), named("1.mp3")),
+		newMockFile("Someone", "Other", inDir("/downloads"), named("2.mp3")),
+	}
+	library := &itunes.Library{Tracks: map[string]itunes.Track{
+		"42": {TrackID: 42, Location: "file:///downloads/2.mp3"},
+	}}
+	relocations, err := Organize(ctx, files, dir, "{artist} - {title}.{ext}", library, ITunesRefuse)
+	if err != ErrITunesTracks || len(relocations) != 0 || len(plan.ContextPlan(ctx).Actions) != 0 {
+		t.Fatalf("Expected nothing to be moved, got %v, %+v and %+v", err, relocations, plan.ContextPlan(ctx).Actions)
+	}
+	relocations, err = Organize(ctx, files, dir, "{artist} - {title}.{ext}", library, ITunesIgnore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := plan.ContextPlan(ctx).Actions
+	if len(relocations) != 2 || relocations[1].ITunesTrack == nil || len(actions) != 2 {
+		t.Fatalf("Expected both files to be moved without relocating iTunes, got %+v and %+v", relocations, actions)
+	}
+}
+
+func TestOrganizeIsIdempotent(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	downloads := t.TempDir()
+	dir := t.TempDir()
+	for _, name := range []string{"1.mp3", "2.mp3"} {
+		err := ioutil.WriteFile(filepath.Join(downloads, name), testMPEG(0, []byte{0xff, 0xfb, 0x90, 0x64}, 417, 4), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []types.File{
+		newMockFile("Someone", "Song", inDir(downloads), named("1.mp3")),
+		newMockFile("Someone", "Song", inDir(downloads), named("2.mp3")),
+	}
+	relocations, err := Organize(ctx, files, dir, "{artist} - {title}.{ext}", nil, ITunesRefuse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relocations) != 2 || relocations[1].To != filepath.Join(dir, "Someone - Song (1).mp3") {
+		t.Fatalf("Expected both files to be moved and the second numbered, got %+v", relocations)
+	}
+	// Organize the moved files again, with the tags the reloaded files would have.
+	for i, relocation := range relocations {
+		files[i] = newMockFile("Someone", "Song", inDir(filepath.Dir(relocation.To)), named(filepath.Base(relocation.To)))
+	}
+	relocations, err = Organize(ctx, files, dir, "{artist} - {title}.{ext}", nil, ITunesRefuse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relocations) != 0 {
+		t.Fatalf("Expected organized files not to be moved again, got %+v", relocations)
+	}
+	for _, name := range []string{"Someone - Song.mp3", "Someone - Song (1).mp3"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected %s to still exist: %v", name, err)
+		}
+	}
+}
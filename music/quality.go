@@ -0,0 +1,57 @@
+package music
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/types"
+)
+
+const defaultLowBitrateTag = "lowbitrate"
+
+// LowBitrateThreshold returns the tag added to low bitrate files and the bitrate in kbps files must be below to get
+// it. A threshold of 0 means files aren't tagged. The tag is lowercased, like every other genre tag, and any whitespace
+// removed, as genre tags are separated by spaces.
+func LowBitrateThreshold(ctx context.Context) (string, int) {
+	conf := configuration.ContextConfiguration(ctx)
+	tag := strings.ToLower(strings.Join(strings.Fields(conf.MusicFiles.LowBitrate.Tag), ""))
+	if tag == "" {
+		tag = defaultLowBitrateTag
+	}
+	return tag, conf.MusicFiles.LowBitrate.Threshold
+}
+
+// Quality summarises the audio quality of the local MP3 files.
+type Quality struct {
+	// Files are the MP3 files whose bitrate could be read, lowest bitrate first.
+	Files []types.File
+	// Modes counts the files of each bitrate mode.
+	Modes map[string]int
+	// Low is how many files are below the threshold.
+	Low int
+}
+
+// BuildQuality sorts the files with a known bitrate by bitrate, and counts the files below the supplied threshold.
+func BuildQuality(files []types.File, threshold int) Quality {
+	quality := Quality{Modes: map[string]int{}}
+	for _, file := range files {
+		if file.Bitrate == 0 {
+			continue
+		}
+		quality.Files = append(quality.Files, file)
+		quality.Modes[file.BitrateMode]++
+		if file.Bitrate < threshold {
+			quality.Low++
+		}
+	}
+	sort.SliceStable(quality.Files, func(i, j int) bool {
+		a, b := quality.Files[i], quality.Files[j]
+		if a.Bitrate != b.Bitrate {
+			return a.Bitrate < b.Bitrate
+		}
+		return a.Path() < b.Path()
+	})
+	return quality
+}
@@ -0,0 +1,68 @@
+package music
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/types"
+)
+
+func TestUpdateLowBitrate(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	conf := configuration.ContextConfiguration(ctx)
+	file := newMockFile("artist", "song")
+	file.SetGenre("house vocal")
+	file.Bitrate = 128
+
+	didUpdate, err := updateLowBitrate(ctx, log.WithField("test", nil), types.FileWithContext{File: file})
+	if err != nil || didUpdate {
+		t.Fatalf("Expected no update without a threshold, got %t %v", didUpdate, err)
+	}
+
+	conf.MusicFiles.LowBitrate.Threshold = 192
+	didUpdate, err = updateLowBitrate(ctx, log.WithField("test", nil), types.FileWithContext{File: file})
+	if err != nil || !didUpdate || file.Genre() != "house lowbitrate vocal" {
+		t.Fatalf("Expected the low bitrate tag to be added, got %q", file.Genre())
+	}
+	didUpdate, _ = updateLowBitrate(ctx, log.WithField("test", nil), types.FileWithContext{File: file})
+	if didUpdate {
+		t.Fatal("Expected no update when already tagged")
+	}
+
+	file.Bitrate = 320
+	didUpdate, err = updateLowBitrate(ctx, log.WithField("test", nil), types.FileWithContext{File: file})
+	if err != nil || !didUpdate || file.Genre() != "house vocal" {
+		t.Fatalf("Expected the low bitrate tag to be removed, got %q", file.Genre())
+	}
+
+	conf.MusicFiles.LowBitrate.Tag = "LowBitrate"
+	file.Bitrate = 128
+	updateLowBitrate(ctx, log.WithField("test", nil), types.FileWithContext{File: file})
+	didUpdate, _ = updateLowBitrate(ctx, log.WithField("test", nil), types.FileWithContext{File: file})
+	if didUpdate || file.Genre() != "house lowbitrate vocal" {
+		t.Fatalf("Expected a configured tag to be lowercased and added once, got %q", file.Genre())
+	}
+
+	conf.MusicFiles.LowBitrate.Tag = "Low Bitrate"
+	didUpdate, _ = updateLowBitrate(ctx, log.WithField("test", nil), types.FileWithContext{File: file})
+	if didUpdate || file.Genre() != "house lowbitrate vocal" {
+		t.Fatalf("Expected the whitespace to be removed from a configured tag, got %q", file.Genre())
+	}
+}
+
+func TestBuildQuality(t *testing.T) {
+	quality := BuildQuality([]types.File{
+		newMockFile("artist", "high.mp3", named("high.mp3"), withBitrate("CBR", 320)),
+		newMockFile("artist", "unknown.flac", named("unknown.flac")),
+		newMockFile("artist", "low.mp3", named("low.mp3"), withBitrate("CBR", 128)),
+		newMockFile("artist", "vbr.mp3", named("vbr.mp3"), withBitrate("VBR", 245)),
+	}, 192)
+	if len(quality.Files) != 3 || quality.Files[0].Filename != "low.mp3" || quality.Files[2].Filename != "high.mp3" {
+		t.Fatalf("Expected the MP3 files lowest bitrate first, got %+v", quality.Files)
+	}
+	if quality.Low != 1 || quality.Modes["CBR"] != 2 || quality.Modes["VBR"] != 1 {
+		t.Fatalf("Expected one low file, two CBR and one VBR, got %d and %v", quality.Low, quality.Modes)
+	}
+}
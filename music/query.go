@@ -0,0 +1,99 @@
+package music
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/snikch/musicmanager/types"
+)
+
+// Presence filters tracks on whether they were matched to a service.
+type Presence string
+
+const (
+	PresenceAny     Presence = ""
+	PresenceHas     Presence = "has"
+	PresenceMissing Presence = "missing"
+)
+
+// Query is a filter over file contexts. Every condition must match.
+type Query struct {
+	// Tags that must all be present in the Genre tag.
+	Tags []string
+	// MinRating is the minimum star rating, from 0 to 5.
+	MinRating int
+	Spotify   Presence
+	ITunes    Presence
+}
+
+// ParseQuery parses a space separated query such as "tag:vocal rating:3 spotify:missing itunes:has".
+func ParseQuery(raw string) (Query, error) {
+	query := Query{}
+	for _, term := range strings.Fields(raw) {
+		parts := strings.SplitN(term, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return query, fmt.Errorf("invalid query term %q, expected field:value", term)
+		}
+		field, value := strings.ToLower(parts[0]), strings.ToLower(parts[1])
+		switch field {
+		case "tag":
+			query.Tags = append(query.Tags, value)
+		case "rating":
+			rating, err := strconv.Atoi(value)
+			if err != nil || rating < 0 || rating > 5 {
+				return query, fmt.Errorf("invalid rating %q, expected 0 to 5", value)
+			}
+			query.MinRating = rating
+		case "spotify", "itunes":
+			presence := Presence(value)
+			if presence != PresenceHas && presence != PresenceMissing {
+				return query, fmt.Errorf("invalid %s value %q, expected has or missing", field, value)
+			}
+			if field == "spotify" {
+				query.Spotify = presence
+			} else {
+				query.ITunes = presence
+			}
+		default:
+			return query, fmt.Errorf("unknown query field %q", field)
+		}
+	}
+	return query, nil
+}
+
+// Match returns true if the file context satisfies every condition of the query.
+func (query Query) Match(ctx context.Context, fileContext types.FileWithContext) bool {
+	if !query.Spotify.match(fileContext.SpotifyTrack != nil) || !query.ITunes.match(fileContext.ITunesTrack != nil) {
+		return false
+	}
+	if query.MinRating > 0 && Rating(ctx, fileContext) < query.MinRating {
+		return false
+	}
+	tags := currentTags(fileContext.Genre())
+	for _, tag := range query.Tags {
+		if !tags[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+func (presence Presence) match(present bool) bool {
+	switch presence {
+	case PresenceHas:
+		return present
+	case PresenceMissing:
+		return !present
+	}
+	return true
+}
+
+// Rating returns the star rating of a file from iTunes, falling back to the rating stored in the comment.
+func Rating(ctx context.Context, fileContext types.FileWithContext) int {
+	if fileContext.ITunesTrack != nil {
+		return fileContext.ITunesTrack.Rating / 20 // iTunes stores a 1 as 20, 2 -> 40 etc.
+	}
+	return ParseComment(ctx, fileContext.Comment()).Rating
+}
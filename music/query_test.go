@@ -0,0 +1,77 @@
+package music
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+func TestParseQuery(t *testing.T) {
+	for _, test := range []struct {
+		Raw   string
+		Query Query
+		Error bool
+	}{
+		{"", Query{}, false},
+		{"tag:Vocal tag:funky", Query{Tags: []string{"vocal", "funky"}}, false},
+		{"rating:3 spotify:missing itunes:has", Query{MinRating: 3, Spotify: PresenceMissing, ITunes: PresenceHas}, false},
+		{"rating:6", Query{}, true},
+		{"spotify:maybe", Query{}, true},
+		{"bpm:120", Query{}, true},
+		{"vocal", Query{}, true},
+	} {
+		query, err := ParseQuery(test.Raw)
+		if test.Error {
+			if err == nil {
+				t.Fatalf("%q: Expected an error", test.Raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: Unexpected error %s", test.Raw, err)
+		}
+		if !reflect.DeepEqual(query, test.Query) {
+			t.Fatalf("%q: Expected %+v but got %+v", test.Raw, test.Query, query)
+		}
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	song := newMockFile("artist", "song")
+	song.SetGenre("house vocal")
+	song.SetComment("xxxx+ - Great")
+	local := types.FileWithContext{File: song}
+	matched := types.FileWithContext{
+		File:         song,
+		SpotifyTrack: &spotifyapi.FullTrack{},
+		ITunesTrack:  &itunes.Track{Rating: 40},
+	}
+	for _, test := range []struct {
+		Raw     string
+		Context types.FileWithContext
+		Match   bool
+	}{
+		{"", local, true},
+		{"tag:vocal tag:house", local, true},
+		{"tag:funky", local, false},
+		{"rating:4", local, true},
+		{"rating:4", matched, false},
+		{"rating:2 spotify:has itunes:has", matched, true},
+		{"spotify:has", local, false},
+		{"itunes:missing", local, true},
+	} {
+		query, err := ParseQuery(test.Raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query.Match(ctx, test.Context) != test.Match {
+			t.Fatalf("%q: Expected match to be %t", test.Raw, test.Match)
+		}
+	}
+}
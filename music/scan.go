@@ -0,0 +1,166 @@
+package music
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/types"
+)
+
+// scanEntry is a music file found while walking the music dirs.
+type scanEntry struct {
+	dir  string
+	info os.FileInfo
+}
+
+// ScanWorkers returns how many goroutines read dirs and parse files at once, from MusicFiles.Workers or the number of
+// CPUs.
+func ScanWorkers(ctx context.Context) int {
+	conf := configuration.ContextConfiguration(ctx)
+	if conf != nil && conf.MusicFiles.Workers > 0 {
+		return conf.MusicFiles.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// walkDirs finds every music file under the supplied dirs, reading at most workers dirs at once. Files are returned
+// in the order a serial depth first walk would find them, regardless of which dirs were read first.
+func walkDirs(roots []string, workers int) ([]scanEntry, error) {
+	listings := map[string][]os.FileInfo{}
+	sem := make(chan struct{}, workers)
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	var firstErr error
+	var read func(dir string)
+	read = func(dir string) {
+		defer wg.Done()
+		sem <- struct{}{}
+		infos, err := ioutil.ReadDir(dir)
+		<-sem
+		mu.Lock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fail.Trace(err)
+			}
+			mu.Unlock()
+			return
+		}
+		listings[dir] = infos
+		mu.Unlock()
+		for _, info := range infos {
+			if info.IsDir() {
+				wg.Add(1)
+				go read(dir + "/" + info.Name())
+			}
+		}
+	}
+	for _, root := range roots {
+		wg.Add(1)
+		go read(root)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	entries := []scanEntry{}
+	var collect func(dir string)
+	collect = func(dir string) {
+		log.WithField("dir", dir).WithField("files", len(listings[dir])).Debug("Found files")
+		for _, info := range listings[dir] {
+			if info.IsDir() {
+				collect(dir + "/" + info.Name())
+				continue
+			}
+			if !IsMusicFile(info.Name()) {
+				log.WithField("name", info.Name()).Debug("Skipping invalid extension")
+				continue
+			}
+			entries = append(entries, scanEntry{dir: dir, info: info})
+		}
+	}
+	for _, root := range roots {
+		collect(root)
+	}
+	return entries, nil
+}
+
+// parseEntries calls parse with every entry and its index across workers goroutines, logging progress as each dir is
+// finished. Once parse returns an error the remaining entries are skipped and the first error is returned.
+func parseEntries(entries []scanEntry, workers int, parse func(i int, entry scanEntry) error) error {
+	remaining := map[string]int{}
+	for _, entry := range entries {
+		remaining[entry.dir]++
+	}
+	dirs := len(remaining)
+	done := 0
+	jobs := make(chan int)
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	var firstErr error
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+				err := parse(i, entries[i])
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				dir := entries[i].dir
+				remaining[dir]--
+				if remaining[dir] == 0 {
+					done++
+					log.WithField("dir", dir).
+						WithField("dirs", done).
+						WithField("total", dirs).
+						Info("Loaded music files from dir")
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// LoadDir parses every music file under the supplied dir, such as a folder of new downloads, without using the
+// library index.
+func LoadDir(ctx context.Context, loc string) ([]types.File, error) {
+	return loadDir(loc, ScanWorkers(ctx))
+}
+
+// loadDir parses every music file under the supplied dir, without using the library index.
+func loadDir(loc string, workers int) ([]types.File, error) {
+	entries, err := walkDirs([]string{loc}, workers)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]types.File, len(entries))
+	err = parseEntries(entries, workers, func(i int, entry scanEntry) error {
+		file, err := LoadFile(entry.dir, entry.info.Name())
+		files[i] = file
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,134 @@
+package music
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFixtureTree creates dirs subdirectories of files mp3s each, plus a file that isn't music, under a temp dir.
+func writeFixtureTree(tb testing.TB, dirs, files int) string {
+	root, err := ioutil.TempDir("", "scan")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("artist %02d", d), "album")
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		for f := 0; f < files; f++ {
+			name := filepath.Join(dir, fmt.Sprintf("%02d track.mp3", f))
+			err = ioutil.WriteFile(name, fixtureMP3(fmt.Sprintf("Artist %d", d), fmt.Sprintf("Track %d", f)), 0644)
+			if err != nil {
+				tb.Fatal(err)
+			}
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, "cover.jpg"), []byte{}, 0644)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return root
+}
+
+// fixtureMP3 returns an ID3v2.3 tag with artist and title frames, followed by padding in place of audio.
+func fixtureMP3(artist, title string) []byte {
+	frames := &bytes.Buffer{}
+	for _, frame := range []struct{ id, text string }{{"TPE1", artist}, {"TIT2", title}} {
+		frames.WriteString(frame.id)
+		binary.Write(frames, binary.BigEndian, uint32(len(frame.text)+1))
+		frames.Write([]byte{0, 0, 0})
+		frames.WriteString(frame.text)
+	}
+	size := frames.Len()
+	out := &bytes.Buffer{}
+	out.WriteString("ID3")
+	out.Write([]byte{3, 0, 0})
+	// The tag size is a synchsafe integer, using seven bits of each byte.
+	out.Write([]byte{byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)})
+	out.Write(frames.Bytes())
+	out.Write(make([]byte, 4096))
+	return out.Bytes()
+}
+
+func TestWalkDirsIsDeterministic(t *testing.T) {
+	root := writeFixtureTree(t, 6, 4)
+	defer os.RemoveAll(root)
+	serial, err := walkDirs([]string{root}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serial) != 24 {
+		t.Fatalf("Expected 24 music files, got %d", len(serial))
+	}
+	for i := 1; i < len(serial); i++ {
+		prev := serial[i-1].dir + "/" + serial[i-1].info.Name()
+		next := serial[i].dir + "/" + serial[i].info.Name()
+		if prev >= next {
+			t.Fatalf("Expected files in walk order, got %q before %q", prev, next)
+		}
+	}
+	for run := 0; run < 5; run++ {
+		concurrent, err := walkDirs([]string{root}, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range serial {
+			if concurrent[i].dir != serial[i].dir || concurrent[i].info.Name() != serial[i].info.Name() {
+				t.Fatalf("Expected the concurrent walk to match the serial walk at %d, got %s/%s", i, concurrent[i].dir, concurrent[i].info.Name())
+			}
+		}
+	}
+}
+
+func TestParseEntries(t *testing.T) {
+	root := writeFixtureTree(t, 3, 3)
+	defer os.RemoveAll(root)
+	entries, err := walkDirs([]string{root}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make([]bool, len(entries))
+	err = parseEntries(entries, 4, func(i int, entry scanEntry) error {
+		seen[i] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range seen {
+		if !seen[i] {
+			t.Fatalf("Expected every entry to be parsed, %d wasn't", i)
+		}
+	}
+	err = parseEntries(entries, 4, func(i int, entry scanEntry) error {
+		return fmt.Errorf("bad file %d", i)
+	})
+	if err == nil {
+		t.Fatal("Expected the parse error to be returned")
+	}
+}
+
+func BenchmarkLoadDir(b *testing.B) {
+	root := writeFixtureTree(b, 20, 25)
+	defer os.RemoveAll(root)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				files, err := loadDir(root, workers)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(files) != 500 {
+					b.Fatalf("Expected 500 files, got %d", len(files))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package music
+
+import (
+	"context"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/state"
+	"github.com/snikch/musicmanager/types"
+)
+
+// LocalRecordings returns the recording key of every file with an artist and title.
+func LocalRecordings(files []types.File) []types.RecordingKey {
+	keys := make([]types.RecordingKey, 0, len(files))
+	for _, file := range files {
+		key := fileRecordingKey(file)
+		if key.Song.Artist == "" || key.Song.Title == "" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// ExcludeUnwanted returns the tracks whose recording isn't marked Don't Want in the context's state store.
+func ExcludeUnwanted(ctx context.Context, tracks spotify.TrackLookup) spotify.TrackLookup {
+	store := state.ContextStore(ctx)
+	if store == nil {
+		return tracks
+	}
+	wanted := spotify.TrackLookup{}
+	for key, track := range tracks {
+		if store.Get(key.Recording()) == state.DontWant {
+			log.WithField("key", key).Debug("Excluding unwanted recording")
+			continue
+		}
+		wanted[key] = track
+	}
+	return wanted
+}
+
+// SetRecordingState sets the state of a recording in the context's state store, recording the change in the plan
+// or journal.
+func SetRecordingState(ctx context.Context, key types.RecordingKey, to state.State) {
+	store := state.ContextStore(ctx)
+	if store == nil {
+		return
+	}
+	from := store.Get(key)
+	if from == to {
+		return
+	}
+	changes := []plan.Change{{Field: "State", Old: string(from), New: string(to)}}
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(plan.Action{Kind: plan.KindStateSet, Target: key.String(), Changes: changes})
+		return
+	}
+	log.WithField("recording", key).WithField("from", from).WithField("to", to).Info("Setting recording state")
+	store.Set(key, to)
+	journal.Record(ctx, journal.Entry{Kind: plan.KindStateSet, Recording: &key, Changes: changes})
+}
+
+func undoStateSet(ctx context.Context, entry journal.Entry) error {
+	store := state.ContextStore(ctx)
+	if store == nil || entry.Recording == nil || len(entry.Changes) == 0 {
+		return nil
+	}
+	change := entry.Changes[0]
+	if current := store.Get(*entry.Recording); string(current) != change.New {
+		log.WithField("recording", *entry.Recording).
+			WithField("current", current).
+			Warn("State has changed since, not restoring it")
+		return nil
+	}
+	SetRecordingState(ctx, *entry.Recording, state.State(change.Old))
+	return nil
+}
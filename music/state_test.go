@@ -0,0 +1,35 @@
+package music
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/state"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+func TestRemoveUnwantedExcludesSpotifyKey(t *testing.T) {
+	store := &state.Store{Recordings: map[types.RecordingKey]state.State{}}
+	ctx := state.ContextWithStore(configuration.ContextWithConfiguration(context.Background()), store)
+	file := newMockFile("A & B", "Song (Original Mix)", withGenre("house delete"))
+	spotifyKey := types.SongKey{Artist: "A, B", Title: "Song"}
+	track := spotifyapi.FullTrack{}
+	graph := spotify.TrackGraph{Tracks: spotify.TrackLookup{spotifyKey: track}}
+	contexts := HydrateSpotifyOnContexts(ctx, FilesToFileContexts(ctx, []types.File{file}), graph)
+	for _, fileContext := range contexts {
+		if fileContext.SpotifyKey != spotifyKey {
+			t.Fatalf("Expected the file to match %v, got %v", spotifyKey, fileContext.SpotifyKey)
+		}
+	}
+
+	_, err := RemoveUnwanted(ctx, graph, contexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := ExcludeUnwanted(ctx, graph.Tracks); len(missing) != 0 {
+		t.Fatalf("Expected the Don't Want track to be excluded, got %v", missing)
+	}
+}
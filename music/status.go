@@ -0,0 +1,142 @@
+package music
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+)
+
+// PlaylistStatus counts how many tracks of a Spotify playlist exist locally.
+type PlaylistStatus struct {
+	Name    string
+	Tracks  int
+	Local   int
+	Missing int
+}
+
+// NearMiss is a local file with no Spotify match whose best candidate scored too low to be matched.
+type NearMiss struct {
+	File  types.File
+	Track types.SongKey
+	Score float64
+}
+
+// Status summarises how local files, the Spotify playlists and the iTunes library line up.
+type Status struct {
+	Files          int
+	SpotifyTracks  int
+	ITunesTracks   int
+	UnknownFiles   []types.File
+	NoSpotifyFiles []types.File
+	NoITunesFiles  []types.File
+	NearMisses     []NearMiss
+	MissingLocal   []types.SongKey
+	DeadITunes     []itunes.Track
+	Playlists      []PlaylistStatus
+}
+
+// BuildStatus compares the local files with the Spotify graph and the iTunes library. The graph is not modified.
+func BuildStatus(ctx context.Context, files []types.File, graph spotify.TrackGraph, library *itunes.Library) Status {
+	status := Status{
+		Files:         len(files),
+		SpotifyTracks: len(graph.Tracks),
+		ITunesTracks:  len(library.Tracks),
+	}
+	// itunesPaths are the files of the iTunes tracks, so retagged files are still found.
+	itunesPaths := map[string]bool{}
+	for _, track := range library.Tracks {
+		if path, err := track.Path(); err == nil {
+			itunesPaths[filepath.Clean(path)] = true
+		}
+	}
+	// local are the graph keys of tracks matched by a local file.
+	local := map[types.SongKey]bool{}
+	matcher := NewGraphMatcher(graph)
+	for _, file := range files {
+		if !itunesPaths[filepath.Clean(file.Path())] {
+			status.NoITunesFiles = append(status.NoITunesFiles, file)
+		}
+		key := fileKey(file)
+		if key.Artist == "" || key.Title == "" {
+			status.UnknownFiles = append(status.UnknownFiles, file)
+			continue
+		}
+		match, ok := matcher.BestFile(file)
+		if ok {
+			local[match.Key] = true
+		} else {
+			status.NoSpotifyFiles = append(status.NoSpotifyFiles, file)
+			if match.Score >= NearMissThreshold {
+				status.NearMisses = append(status.NearMisses, NearMiss{File: file, Track: match.Key, Score: match.Score})
+			}
+		}
+	}
+
+	sort.SliceStable(status.NearMisses, func(i, j int) bool {
+		return status.NearMisses[i].Score > status.NearMisses[j].Score
+	})
+
+	// LeftOuterJoinFilesToGraph removes found tracks from the lookup, so give it a copy.
+	tracks := spotify.TrackLookup{}
+	for key, track := range graph.Tracks {
+		tracks[key] = track
+	}
+	missing := LeftOuterJoinFilesToGraph(ctx, files, spotify.TrackGraph{UserID: graph.UserID, Tracks: tracks, Playlists: graph.Playlists})
+	for key := range missing {
+		status.MissingLocal = append(status.MissingLocal, key)
+	}
+	sort.Slice(status.MissingLocal, func(i, j int) bool {
+		return songKeyLess(status.MissingLocal[i], status.MissingLocal[j])
+	})
+
+	for _, track := range library.Tracks {
+		path, err := track.Path()
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			log.WithField("location", path).Debug("iTunes track file no longer exists")
+			status.DeadITunes = append(status.DeadITunes, track)
+		}
+	}
+	sort.Slice(status.DeadITunes, func(i, j int) bool {
+		return status.DeadITunes[i].Location < status.DeadITunes[j].Location
+	})
+
+	playlists := map[string]*PlaylistStatus{}
+	for key, keyPlaylists := range graph.Playlists {
+		for _, playlist := range keyPlaylists {
+			counts, ok := playlists[playlist.Name]
+			if !ok {
+				counts = &PlaylistStatus{Name: playlist.Name}
+				playlists[playlist.Name] = counts
+			}
+			counts.Tracks++
+			if local[key] {
+				counts.Local++
+			} else {
+				counts.Missing++
+			}
+		}
+	}
+	for _, counts := range playlists {
+		status.Playlists = append(status.Playlists, *counts)
+	}
+	sort.Slice(status.Playlists, func(i, j int) bool {
+		return status.Playlists[i].Name < status.Playlists[j].Name
+	})
+	return status
+}
+
+func songKeyLess(a, b types.SongKey) bool {
+	if a.Artist != b.Artist {
+		return a.Artist < b.Artist
+	}
+	return a.Title < b.Title
+}
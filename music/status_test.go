@@ -0,0 +1,57 @@
+package music
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+func TestBuildStatus(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	files := []types.File{
+		newMockFile("a", "one", inDir("/nonexistent/musicmanager"), named("one.mp3")),
+		newMockFile("b", "two", inDir("/nonexistent/musicmanager"), named("two.mp3")),
+		newMockFile("", "unknown", inDir("/nonexistent/musicmanager"), named("unknown.mp3")),
+	}
+	one := types.SongKey{Artist: "a", Title: "one"}
+	three := types.SongKey{Artist: "c", Title: "three"}
+	vocal := spotifyapi.SimplePlaylist{ID: "1", Name: "House: Vocal"}
+	graph := spotify.TrackGraph{
+		Tracks: spotify.TrackLookup{one: {}, three: {}},
+		Playlists: spotify.PlaylistLookup{
+			one:   {vocal},
+			three: {vocal},
+		},
+	}
+	library := &itunes.Library{Tracks: map[string]itunes.Track{
+		// Retitled in iTunes since, but still the same file.
+		"1": {Artist: "a", Name: "one (retitled)", Location: "file:///nonexistent/musicmanager/one.mp3"},
+		"2": {Location: "file:///nonexistent/musicmanager/unknown.mp3"},
+	}}
+
+	status := BuildStatus(ctx, files, graph, library)
+	if len(status.UnknownFiles) != 1 || len(status.NoSpotifyFiles) != 1 || len(status.NoITunesFiles) != 1 {
+		t.Fatalf("Expected 1 unknown, unmatched and non iTunes file, got %+v", status)
+	}
+	if len(status.MissingLocal) != 1 || status.MissingLocal[0] != three {
+		t.Fatalf("Expected %v to be missing locally, got %v", three, status.MissingLocal)
+	}
+	if status.NoITunesFiles[0].Filename != "two.mp3" {
+		t.Fatalf("Expected two.mp3 to be missing from iTunes, got %s", status.NoITunesFiles[0].Filename)
+	}
+	if len(status.DeadITunes) != 2 {
+		t.Fatalf("Expected 2 dead iTunes tracks, got %d", len(status.DeadITunes))
+	}
+	if len(graph.Tracks) != 2 {
+		t.Fatalf("Expected the graph to be unmodified, got %d tracks", len(graph.Tracks))
+	}
+	expected := PlaylistStatus{Name: "House: Vocal", Tracks: 2, Local: 1, Missing: 1}
+	if len(status.Playlists) != 1 || status.Playlists[0] != expected {
+		t.Fatalf("Expected playlist status %+v, got %+v", expected, status.Playlists)
+	}
+}
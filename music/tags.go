@@ -7,26 +7,30 @@ import (
 	"strings"
 
 	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
 	"github.com/zmb3/spotify"
 
 	"github.com/sirupsen/logrus"
 	"github.com/snikch/api/fail"
 	"github.com/snikch/api/log"
-	"github.com/snikch/musicmanager/spotifyclient"
+	"github.com/snikch/musicmanager/service"
 	"github.com/snikch/musicmanager/types"
 )
 
 var (
 	tagProcessors = map[string]func(context.Context, *logrus.Entry, types.FileWithContext) (bool, error){
-		"year":    updateYear,
-		"comment": updateComment,
-		"genre":   updateGenre,
+		"year":       updateYear,
+		"comment":    updateComment,
+		"genre":      updateGenre,
+		"lowbitrate": updateLowBitrate,
 	}
 )
 
 func updateFileWithPlaylistTags(ctx context.Context, fileContext types.FileWithContext) error {
 	l := log.WithField("title", fileContext.Title()).
 		WithField("artist", fileContext.Artist())
+	before := tagValues(fileContext)
 	anyUpdate := false
 	for name, processor := range tagProcessors {
 		didUpdate, err := processor(ctx, l.WithField("processor", name), fileContext)
@@ -40,7 +44,54 @@ func updateFileWithPlaylistTags(ctx context.Context, fileContext types.FileWithC
 	if !anyUpdate {
 		return nil
 	}
-	return fail.Trace(fileContext.Save())
+	return saveTags(ctx, fileContext.File, tagChanges(before, tagValues(fileContext)))
+}
+
+// saveTags saves the file's updated tags and journals the changes, or records them on the plan in dry-run mode.
+func saveTags(ctx context.Context, file types.File, changes []plan.Change) error {
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(plan.Action{
+			Kind:    plan.KindTagWrite,
+			Target:  file.Path(),
+			Changes: changes,
+		})
+		return nil
+	}
+	err := file.Save()
+	if err != nil {
+		return fail.Trace(err)
+	}
+	journal.Record(ctx, journal.Entry{
+		Kind:    plan.KindTagWrite,
+		Path:    file.Path(),
+		Changes: changes,
+	})
+	return nil
+}
+
+// tagValues returns the current value of each tag the processors may update.
+func tagValues(song types.Song) map[string]string {
+	return map[string]string{
+		"Genre":   song.Genre(),
+		"Year":    song.Year(),
+		"Comment": song.Comment(),
+		"ISRC":    song.ISRC(),
+	}
+}
+
+// tagChanges returns the difference between two sets of tag values, ordered by field.
+func tagChanges(before, after map[string]string) []plan.Change {
+	changes := []plan.Change{}
+	for field, value := range after {
+		if before[field] == value {
+			continue
+		}
+		changes = append(changes, plan.Change{Field: field, Old: before[field], New: value})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
 }
 
 func updateYear(ctx context.Context, l *logrus.Entry, fileContext types.FileWithContext) (bool, error) {
@@ -49,13 +100,16 @@ func updateYear(ctx context.Context, l *logrus.Entry, fileContext types.FileWith
 	}
 	didUpdate := false
 	if fileContext.Year() == "" {
-		client := spotifyclient.ContextClient(ctx)
-		album, err := client.GetAlbum(fileContext.SpotifyTrack.Album.ID)
+		albums, err := service.ContextService(ctx).Albums(fileContext.SpotifyTrack.Album.ID)
 		if err != nil {
 			log.WithError(err).WithField("id", fileContext.SpotifyTrack.Album.ID).Error("Failed to get album from spotify to update Year")
 			return false, err
 		}
-		year := strconv.Itoa(album.ReleaseDateTime().Year())
+		if len(albums) == 0 || albums[0] == nil {
+			l.WithField("id", fileContext.SpotifyTrack.Album.ID).Warn("Spotify album not found, can't set Year tag")
+			return false, nil
+		}
+		year := strconv.Itoa(albums[0].ReleaseDateTime().Year())
 		fileContext.SetYear(year)
 		didUpdate = true
 		l.WithField("year", year).Info("Set Year tag")
@@ -92,7 +146,11 @@ func updateComment(ctx context.Context, l *logrus.Entry, fileContext types.FileW
 
 func updateGenre(ctx context.Context, l *logrus.Entry, fileContext types.FileWithContext) (bool, error) {
 	removals := removalTags(ctx)
-	playlist, removedPlaylist := removeTags(playlistTags(ctx, fileContext.SpotifyPlaylists), removals)
+	playlists := fileContext.SpotifyPlaylists
+	if configuration.ContextConfiguration(ctx).MusicFiles.SongPlaylistTags {
+		playlists = append(append([]spotify.SimplePlaylist{}, playlists...), fileContext.SongPlaylists...)
+	}
+	playlist, removedPlaylist := removeTags(playlistTags(ctx, playlists), removals)
 	current, removedCurrent := removeTags(currentTags(replaceTags(ctx, fileContext.Genre())), removals)
 	target := mergeTags(playlist, current)
 	tags := flattenTags(target)
@@ -113,6 +171,33 @@ func updateGenre(ctx context.Context, l *logrus.Entry, fileContext types.FileWit
 	return true, nil
 }
 
+// updateLowBitrate adds the low bitrate tag to the genre of MP3 files below the configured bitrate, and removes it
+// from files that are no longer below it, e.g. after being replaced.
+func updateLowBitrate(ctx context.Context, l *logrus.Entry, fileContext types.FileWithContext) (bool, error) {
+	tag, threshold := LowBitrateThreshold(ctx)
+	if threshold <= 0 || fileContext.Bitrate == 0 {
+		return false, nil
+	}
+	tags := currentTags(fileContext.Genre())
+	low := fileContext.Bitrate < threshold
+	if tags[tag] == low {
+		return false, nil
+	}
+	if low {
+		tags[tag] = true
+	} else {
+		delete(tags, tag)
+	}
+	genre := flattenTags(tags)
+	sort.Strings(genre)
+	l.WithField("bitrate", fileContext.Bitrate).
+		WithField("threshold", threshold).
+		WithField("low", low).
+		Info("Updating low bitrate tag")
+	fileContext.SetGenre(strings.Join(genre, " "))
+	return true, nil
+}
+
 func playlistNameToGenres(name string) []string {
 	parts := strings.Split(name, " ")
 	genres := make([]string, 0, len(parts))
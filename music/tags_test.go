@@ -5,9 +5,11 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/snikch/api/log"
 	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/plan"
 	"github.com/snikch/musicmanager/types"
 	"github.com/zmb3/spotify"
 )
@@ -18,6 +20,7 @@ type MockSong struct {
 	year    string
 	title   string
 	artist  string
+	isrc    string
 }
 
 func (f *MockSong) Artist() string {
@@ -40,6 +43,14 @@ func (f *MockSong) Title() string {
 	return f.title
 }
 
+func (f *MockSong) ISRC() string {
+	return f.isrc
+}
+
+func (f *MockSong) SetISRC(isrc string) {
+	f.isrc = isrc
+}
+
 func (f *MockSong) SetComment(comment string) {
 	f.comment = comment
 }
@@ -56,13 +67,43 @@ func (f *MockSong) Save() error {
 	return nil
 }
 
-func newMockFile(artist, title string) types.File {
-	return types.File{
+// mockFileOption sets a field of a file returned by newMockFile.
+type mockFileOption func(*types.File)
+
+func newMockFile(artist, title string, options ...mockFileOption) types.File {
+	file := types.File{
 		Song: &MockSong{
 			title:  title,
 			artist: artist,
 		},
 	}
+	for _, option := range options {
+		option(&file)
+	}
+	return file
+}
+
+func inDir(dir string) mockFileOption {
+	return func(file *types.File) { file.Dir = dir }
+}
+
+func named(filename string) mockFileOption {
+	return func(file *types.File) { file.Filename = filename }
+}
+
+func withGenre(genre string) mockFileOption {
+	return func(file *types.File) { file.SetGenre(genre) }
+}
+
+func withDuration(duration time.Duration) mockFileOption {
+	return func(file *types.File) { file.Duration = duration }
+}
+
+func withBitrate(mode string, bitrate int) mockFileOption {
+	return func(file *types.File) {
+		file.BitrateMode = mode
+		file.Bitrate = bitrate
+	}
 }
 
 func TestUpdateGenreAddsTags(t *testing.T) {
@@ -75,6 +116,22 @@ func TestUpdateGenreAddsTags(t *testing.T) {
 	tagMatch(t, song.Genre(), "p1 p2 p3")
 }
 
+func TestUpdateGenreSongPlaylists(t *testing.T) {
+	ctx := configuration.ContextWithConfiguration(context.Background())
+	song := newMockFile("artist", "song")
+	fileContext := types.FileWithContext{
+		File:             song,
+		SpotifyPlaylists: []spotify.SimplePlaylist{{Name: "P1"}},
+		SongPlaylists:    []spotify.SimplePlaylist{{Name: "P2"}},
+	}
+	updateGenre(ctx, log.WithField("test", nil), fileContext)
+	tagMatch(t, song.Genre(), "p1")
+
+	configuration.ContextConfiguration(ctx).MusicFiles.SongPlaylistTags = true
+	updateGenre(ctx, log.WithField("test", nil), fileContext)
+	tagMatch(t, song.Genre(), "p1 p2")
+}
+
 func TestUpdateGenreLeavesTags(t *testing.T) {
 	ctx := configuration.ContextWithConfiguration(context.Background())
 	song := newMockFile("artist", "song")
@@ -136,6 +193,26 @@ func TestUpdateGenreSortsUnchangedTags(t *testing.T) {
 	tagMatch(t, song.Genre(), "a b c")
 }
 
+func TestUpdateFileWithPlaylistTagsDryRun(t *testing.T) {
+	ctx := plan.ContextWithPlan(configuration.ContextWithConfiguration(context.Background()))
+	song := newMockFile("artist", "song")
+	song.SetGenre("x1")
+	err := updateFileWithPlaylistTags(ctx, types.FileWithContext{File: song, SpotifyPlaylists: []spotify.SimplePlaylist{
+		{Name: "P1"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := plan.ContextPlan(ctx).Actions
+	if len(actions) != 1 {
+		t.Fatalf("Expected 1 planned action but got %d", len(actions))
+	}
+	expected := []plan.Change{{Field: "Genre", Old: "x1", New: "p1 x1"}}
+	if !reflect.DeepEqual(actions[0].Changes, expected) {
+		t.Fatalf("Expected changes %v but got %v", expected, actions[0].Changes)
+	}
+}
+
 func tagMatch(t *testing.T, a, b string) {
 	aSlice := strings.Split(a, " ")
 	bSlice := strings.Split(b, " ")
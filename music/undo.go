@@ -0,0 +1,194 @@
+package music
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+	spotifyapi "github.com/zmb3/spotify"
+)
+
+// Undo reverses the supplied journal entries of a run, newest first. Tags are only restored if they haven't been
+// changed again since. iTunes deletions can't be undone, as the file was moved to the trash by Finder.
+func Undo(ctx context.Context, entries []journal.Entry) error {
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		var err error
+		switch entry.Kind {
+		case plan.KindTagWrite:
+			err = undoTagWrite(ctx, entry)
+		case plan.KindPlaylistRemove:
+			err = undoPlaylistRemove(ctx, entry)
+		case plan.KindPlaylistAdd:
+			err = undoPlaylistAdd(ctx, entry)
+		case plan.KindFileMove:
+			_, err = MoveFile(ctx, entry.To, entry.Path)
+		case plan.KindITunesRelocate:
+			err = relocateITunesTrack(ctx, entry.ITunesTrackID, entry.To, entry.Path)
+		case plan.KindFollowArtist:
+			err = undoFollowArtist(ctx, entry)
+		case plan.KindUnfollowArtist:
+			err = undoUnfollowArtist(ctx, entry)
+		case plan.KindStateSet:
+			err = undoStateSet(ctx, entry)
+		case plan.KindITunesDelete:
+			log.WithField("path", entry.Path).Warn("Can't undo iTunes deletion, restore the file from the trash and re-add it to iTunes")
+		default:
+			log.WithField("kind", entry.Kind).Warn("Don't know how to undo journal entry")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func undoTagWrite(ctx context.Context, entry journal.Entry) error {
+	l := log.WithField("path", entry.Path)
+	file, err := LoadFile(filepath.Dir(entry.Path), filepath.Base(entry.Path))
+	if err != nil {
+		return err
+	}
+	before := tagValues(file)
+	for _, change := range entry.Changes {
+		if before[change.Field] != change.New {
+			l.WithField("field", change.Field).
+				WithField("current", before[change.Field]).
+				Warn("Tag has changed since, not restoring it")
+			continue
+		}
+		setTag(file, change.Field, change.Old)
+	}
+	changes := tagChanges(before, tagValues(file))
+	if len(changes) == 0 {
+		return nil
+	}
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(plan.Action{Kind: plan.KindTagWrite, Target: entry.Path, Changes: changes})
+		return nil
+	}
+	l.WithField("changes", changes).Info("Restoring tags")
+	err = file.Save()
+	if err != nil {
+		return fail.Trace(err)
+	}
+	journal.Record(ctx, journal.Entry{Kind: plan.KindTagWrite, Path: entry.Path, Changes: changes})
+	return nil
+}
+
+func setTag(song types.Song, field, value string) {
+	switch field {
+	case "Genre":
+		song.SetGenre(value)
+	case "Year":
+		song.SetYear(value)
+	case "Comment":
+		song.SetComment(value)
+	case "ISRC":
+		song.SetISRC(value)
+	}
+}
+
+func undoPlaylistRemove(ctx context.Context, entry journal.Entry) error {
+	positions := append([]int{}, entry.Positions...)
+	sort.Ints(positions)
+	if len(positions) == 0 {
+		// Without a recorded position the track is added to the end.
+		positions = []int{1 << 30}
+	}
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(undoPlaylistAction(plan.KindPlaylistAdd, entry))
+		return nil
+	}
+	log.WithField("playlist", entry.PlaylistName).
+		WithField("trackID", entry.TrackID).
+		WithField("positions", entry.Positions).
+		Info("Restoring track to playlist")
+	for _, position := range positions {
+		err := spotify.InsertTrack(ctx, entry.UserID, spotifyapi.ID(entry.PlaylistID), spotifyapi.ID(entry.TrackID), position)
+		if err != nil {
+			return err
+		}
+	}
+	journal.Record(ctx, journal.Entry{
+		Kind:         plan.KindPlaylistAdd,
+		UserID:       entry.UserID,
+		PlaylistID:   entry.PlaylistID,
+		PlaylistName: entry.PlaylistName,
+		TrackID:      entry.TrackID,
+	})
+	return nil
+}
+
+func undoPlaylistAdd(ctx context.Context, entry journal.Entry) error {
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(undoPlaylistAction(plan.KindPlaylistRemove, entry))
+		return nil
+	}
+	log.WithField("playlist", entry.PlaylistName).
+		WithField("trackID", entry.TrackID).
+		Info("Removing added track from playlist")
+	playlistID := spotifyapi.ID(entry.PlaylistID)
+	positions, err := spotify.PlaylistPositions(ctx, entry.UserID, playlistID)
+	if err != nil {
+		return err
+	}
+	err = service.ContextService(ctx).RemovePlaylistTracks(entry.UserID, playlistID, spotifyapi.ID(entry.TrackID))
+	if err != nil {
+		return err
+	}
+	journal.Record(ctx, journal.Entry{
+		Kind:         plan.KindPlaylistRemove,
+		UserID:       entry.UserID,
+		PlaylistID:   entry.PlaylistID,
+		PlaylistName: entry.PlaylistName,
+		TrackID:      entry.TrackID,
+		Positions:    positions[spotifyapi.ID(entry.TrackID)],
+	})
+	return nil
+}
+
+func undoFollowArtist(ctx context.Context, entry journal.Entry) error {
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(plan.Action{Kind: plan.KindUnfollowArtist, Target: entry.ArtistID})
+		return nil
+	}
+	log.WithField("id", entry.ArtistID).Info("Unfollowing artist")
+	err := service.ContextService(ctx).UnfollowArtists(spotifyapi.ID(entry.ArtistID))
+	if err != nil {
+		return err
+	}
+	journal.Record(ctx, journal.Entry{Kind: plan.KindUnfollowArtist, ArtistID: entry.ArtistID})
+	return nil
+}
+
+func undoUnfollowArtist(ctx context.Context, entry journal.Entry) error {
+	if p := plan.ContextPlan(ctx); p != nil {
+		p.Add(plan.Action{Kind: plan.KindFollowArtist, Target: entry.ArtistID})
+		return nil
+	}
+	log.WithField("id", entry.ArtistID).Info("Following artist")
+	err := service.ContextService(ctx).FollowArtists(spotifyapi.ID(entry.ArtistID))
+	if err != nil {
+		return err
+	}
+	journal.Record(ctx, journal.Entry{Kind: plan.KindFollowArtist, ArtistID: entry.ArtistID})
+	return nil
+}
+
+func undoPlaylistAction(kind plan.Kind, entry journal.Entry) plan.Action {
+	return plan.Action{
+		Kind:   kind,
+		Target: entry.TrackID,
+		Details: map[string]string{
+			"playlist": entry.PlaylistName,
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package music
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/journal"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
+	"github.com/zmb3/spotify"
+)
+
+func TestUndoPlaylistChanges(t *testing.T) {
+	fake := service.NewFake("user")
+	tracks := []spotify.FullTrack{{}, {}, {}}
+	for i, id := range []spotify.ID{"a", "c", "added"} {
+		tracks[i].ID = id
+	}
+	playlist := fake.AddPlaylist("House: Vocal", tracks...)
+	fake.Tracks["b"] = spotify.FullTrack{}
+	fake.Following["artist"] = true
+	ctx := service.ContextWithService(configuration.ContextWithConfiguration(context.Background()), fake)
+
+	entries := []journal.Entry{
+		{Kind: plan.KindPlaylistRemove, UserID: "user", PlaylistID: string(playlist.ID), TrackID: "b", Positions: []int{1}},
+		{Kind: plan.KindPlaylistAdd, UserID: "user", PlaylistID: string(playlist.ID), TrackID: "added"},
+		{Kind: plan.KindFollowArtist, ArtistID: "artist"},
+		{Kind: plan.KindUnfollowArtist, ArtistID: "unfollowed"},
+	}
+	err := Undo(ctx, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []spotify.ID{"a", "b", "c"}
+	if !reflect.DeepEqual(fake.PlaylistTrackIDs[playlist.ID], expected) {
+		t.Fatalf("Expected playlist %v, got %v", expected, fake.PlaylistTrackIDs[playlist.ID])
+	}
+	if fake.Following["artist"] {
+		t.Fatal("Expected the artist to be unfollowed")
+	}
+	if !fake.Following["unfollowed"] {
+		t.Fatal("Expected the unfollowed artist to be followed again")
+	}
+}
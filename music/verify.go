@@ -0,0 +1,70 @@
+package music
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/types"
+)
+
+// DefaultVerifyTolerance is how different a local file's length can be from its Spotify track's and still be the same
+// version. Encoders add a little silence, but a radio edit is usually minutes shorter than an extended mix.
+const DefaultVerifyTolerance = 5 * time.Second
+
+// DurationMismatch is a local file whose length differs from the Spotify track it matched, e.g. a radio edit
+// downloaded instead of the extended mix in a playlist.
+type DurationMismatch struct {
+	File    types.File
+	Track   types.SongKey
+	Local   time.Duration
+	Spotify time.Duration
+}
+
+// Shorter returns true if the local file is shorter than the Spotify version.
+func (mismatch DurationMismatch) Shorter() bool {
+	return mismatch.Local < mismatch.Spotify
+}
+
+// Difference returns how much longer the local file is than the Spotify version, which is negative if it's
+// shorter.
+func (mismatch DurationMismatch) Difference() time.Duration {
+	return mismatch.Local - mismatch.Spotify
+}
+
+// VerifyDurations returns every file matched to a Spotify track whose length differs from the track's by more than
+// the tolerance, ordered by path, and how many matched files were skipped as their length can't be read.
+func VerifyDurations(contexts types.FileContexts, tolerance time.Duration) ([]DurationMismatch, int) {
+	mismatches := []DurationMismatch{}
+	skipped := 0
+	for key, fileContext := range contexts {
+		if fileContext.SpotifyTrack == nil || fileContext.SpotifyTrack.Duration <= 0 {
+			continue
+		}
+		local := FileDuration(fileContext.File)
+		if local == 0 {
+			log.WithField("key", key).WithField("name", fileContext.Filename).Debug("Unknown length, can't verify")
+			skipped++
+			continue
+		}
+		artists := []string{}
+		for _, artist := range fileContext.SpotifyTrack.Artists {
+			artists = append(artists, artist.Name)
+		}
+		mismatch := DurationMismatch{
+			File:    fileContext.File,
+			Track:   types.SongKey{Artist: strings.Join(artists, ", "), Title: fileContext.SpotifyTrack.Name},
+			Local:   local,
+			Spotify: time.Duration(fileContext.SpotifyTrack.Duration) * time.Millisecond,
+		}
+		if difference := mismatch.Difference(); difference <= tolerance && difference >= -tolerance {
+			continue
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].File.Path() < mismatches[j].File.Path()
+	})
+	return mismatches, skipped
+}
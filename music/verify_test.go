@@ -0,0 +1,37 @@
+package music
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snikch/musicmanager/types"
+	"github.com/zmb3/spotify"
+)
+
+func TestVerifyDurations(t *testing.T) {
+	track := func(name string, duration time.Duration) *spotify.FullTrack {
+		track := &spotify.FullTrack{}
+		track.Name = name
+		track.Artists = []spotify.SimpleArtist{{Name: "a"}, {Name: "b"}}
+		track.Duration = int(duration / time.Millisecond)
+		return track
+	}
+	contexts := types.FileContexts{
+		{Artist: "a", Title: "radio"}:     {File: newMockFile("a", "radio", named("radio.mp3"), withDuration(3*time.Minute)), SpotifyTrack: track("radio (Extended Mix)", 6*time.Minute)},
+		{Artist: "a", Title: "same"}:      {File: newMockFile("a", "same", named("same.mp3"), withDuration(6*time.Minute+2*time.Second)), SpotifyTrack: track("same", 6*time.Minute)},
+		{Artist: "a", Title: "extended"}:  {File: newMockFile("a", "extended", named("extended.mp3"), withDuration(7*time.Minute)), SpotifyTrack: track("extended (Radio Edit)", 3*time.Minute)},
+		{Artist: "a", Title: "unmatched"}: {File: newMockFile("a", "unmatched", named("unmatched.mp3"), withDuration(time.Minute))},
+		{Artist: "a", Title: "unknown"}:   {File: newMockFile("a", "unknown", named("unknown.ogg")), SpotifyTrack: track("unknown", 6*time.Minute)},
+	}
+	mismatches, skipped := VerifyDurations(contexts, 5*time.Second)
+	if len(mismatches) != 2 || skipped != 1 {
+		t.Fatalf("Expected two mismatches and one skipped file, got %+v and %d skipped", mismatches, skipped)
+	}
+	extended, radio := mismatches[0], mismatches[1]
+	if extended.Shorter() || extended.Difference() != 4*time.Minute || extended.Track.Artist != "a, b" {
+		t.Fatalf("Expected the extended mix to be 4 minutes longer, got %+v", extended)
+	}
+	if !radio.Shorter() || radio.Difference() != -3*time.Minute || radio.Track.Title != "radio (Extended Mix)" {
+		t.Fatalf("Expected the radio edit to be 3 minutes shorter, got %+v", radio)
+	}
+}
@@ -0,0 +1,222 @@
+package music
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/snikch/api/fail"
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/configuration"
+	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/spotify"
+	"github.com/snikch/musicmanager/types"
+)
+
+// fileState is the size and modification time of a file, used to ignore events caused by our own tag writes.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watcher tags files in the music directories as they're created or changed.
+type watcher struct {
+	graph      spotify.TrackGraph
+	catalog    *types.Catalog
+	libraryLoc string
+	library    *itunes.Library
+	libraryMod time.Time
+	debounce   time.Duration
+	notify     *fsnotify.Watcher
+	ready      chan *pendingFile
+	pending    map[string]*pendingFile
+	processed  map[string]fileState
+}
+
+// pendingFile is a file waiting for its debounce timer. Once the timer fires the pendingFile is sent to ready.
+type pendingFile struct {
+	path  string
+	timer *time.Timer
+}
+
+// Watch monitors every configured music directory and runs the tag processors over new and changed files once they
+// have stopped changing for the debounce duration. Files are matched against the supplied graph and the iTunes
+// library at libraryLoc, which is reloaded whenever it changes, and linked to their songs in the supplied catalog.
+// Watch blocks until the context is cancelled.
+func Watch(ctx context.Context, graph spotify.TrackGraph, catalog *types.Catalog, libraryLoc string, debounce time.Duration) error {
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fail.Trace(err)
+	}
+	defer notify.Close()
+	w := &watcher{
+		graph:      graph,
+		catalog:    catalog,
+		libraryLoc: libraryLoc,
+		debounce:   debounce,
+		notify:     notify,
+		ready:      make(chan *pendingFile),
+		pending:    map[string]*pendingFile{},
+		processed:  map[string]fileState{},
+	}
+	conf := configuration.ContextConfiguration(ctx)
+	for _, dir := range conf.MusicFiles.Dirs {
+		err := w.addDir(ctx, dir, false)
+		if err != nil {
+			return err
+		}
+	}
+	log.WithField("dirs", conf.MusicFiles.Dirs).Info("Watching for new music files")
+	for {
+		select {
+		case <-ctx.Done():
+			for _, pending := range w.pending {
+				pending.timer.Stop()
+			}
+			return nil
+		case err := <-notify.Errors:
+			log.WithError(err).Error("File watcher error")
+		case event, ok := <-notify.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(ctx, event)
+		case fired := <-w.ready:
+			if w.pending[fired.path] != fired {
+				// The file changed again after this timer fired, a newer timer is pending.
+				continue
+			}
+			delete(w.pending, fired.path)
+			w.process(ctx, fired.path)
+		}
+	}
+}
+
+// addDir watches the directory and all of its subdirectories. When schedule is true, any music files already in them
+// are scheduled for tagging, e.g. when a downloaded album folder is moved into a watched directory.
+func (w *watcher) addDir(ctx context.Context, dir string, schedule bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			log.WithField("dir", path).Debug("Watching dir")
+			return fail.Trace(w.notify.Add(path))
+		}
+		if schedule && IsMusicFile(path) {
+			w.schedule(ctx, path)
+		}
+		return nil
+	})
+}
+
+func (w *watcher) handleEvent(ctx context.Context, event fsnotify.Event) {
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+		return
+	}
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		if event.Op&fsnotify.Create == fsnotify.Create {
+			err := w.addDir(ctx, event.Name, true)
+			if err != nil {
+				log.WithError(err).WithField("dir", event.Name).Error("Failed to watch new dir")
+			}
+		}
+		return
+	}
+	if !IsMusicFile(event.Name) {
+		return
+	}
+	if state, ok := w.processed[event.Name]; ok && state == statFile(info) {
+		// This is the event from our own tag write.
+		return
+	}
+	w.schedule(ctx, event.Name)
+}
+
+// schedule processes the file once it has stopped changing for the debounce duration.
+func (w *watcher) schedule(ctx context.Context, path string) {
+	// A timer that has already fired may be waiting to send, so it's replaced rather than reset.
+	if pending, ok := w.pending[path]; ok && pending.timer.Stop() {
+		pending.timer.Reset(w.debounce)
+		return
+	}
+	log.WithField("path", path).Debug("Scheduling file")
+	pending := &pendingFile{path: path}
+	pending.timer = time.AfterFunc(w.debounce, func() {
+		select {
+		case w.ready <- pending:
+		case <-ctx.Done():
+		}
+	})
+	w.pending[path] = pending
+}
+
+// process hydrates a single file against the graph and iTunes library, then runs the tag processors on it.
+func (w *watcher) process(ctx context.Context, path string) {
+	l := log.WithField("path", path)
+	file, err := LoadFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		l.WithError(err).Warn("Failed to load file")
+		return
+	}
+	defer closeFile(file)
+	contexts := FilesToFileContexts(ctx, []types.File{file})
+	contexts = HydrateSpotifyOnContexts(ctx, contexts, w.graph)
+	library, err := w.loadLibrary()
+	if err != nil {
+		l.WithError(err).Warn("Failed to load iTunes library, tagging without it")
+	} else {
+		contexts = HydrateITunesOnContexts(ctx, contexts, library)
+	}
+	contexts = HydrateCatalogOnContexts(ctx, contexts, w.catalog, w.graph)
+	l.Info("Tagging file")
+	err = UpdateFilesTags(ctx, contexts)
+	if err != nil {
+		l.WithError(err).Error("Failed to tag file")
+		return
+	}
+	info, err := os.Stat(path)
+	if err == nil {
+		w.processed[path] = statFile(info)
+	}
+}
+
+// loadLibrary returns the iTunes library, reloading it if the XML has changed since it was last loaded.
+func (w *watcher) loadLibrary() (*itunes.Library, error) {
+	info, err := os.Stat(w.libraryLoc)
+	if err != nil {
+		return nil, err
+	}
+	if w.library != nil && info.ModTime().Equal(w.libraryMod) {
+		return w.library, nil
+	}
+	library, err := itunes.LoadLibrary(w.libraryLoc)
+	if err != nil {
+		return nil, err
+	}
+	w.library = library
+	w.libraryMod = info.ModTime()
+	return library, nil
+}
+
+// closeFile releases the file handle held by the underlying tag library, as the watcher is long running.
+func closeFile(file types.File) {
+	song := file.Song
+	if clean, ok := song.(types.CleanWrapper); ok {
+		song = clean.Song
+	}
+	if closer, ok := song.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func statFile(info os.FileInfo) fileState {
+	return fileState{size: info.Size(), modTime: info.ModTime()}
+}
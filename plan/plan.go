@@ -0,0 +1,134 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kind describes the type of mutation an action would perform.
+type Kind string
+
+const (
+	KindTagWrite       Kind = "tag-write"
+	KindPlaylistCreate Kind = "playlist-create"
+	KindPlaylistClear  Kind = "playlist-clear"
+	KindPlaylistAdd    Kind = "playlist-add"
+	KindPlaylistRemove Kind = "playlist-remove"
+	KindITunesDelete   Kind = "itunes-delete"
+	KindFollowArtist   Kind = "follow-artist"
+	KindUnfollowArtist Kind = "unfollow-artist"
+	KindFileMove       Kind = "file-move"
+	KindStateSet       Kind = "state-set"
+	KindITunesRelocate Kind = "itunes-relocate"
+)
+
+// Change is a single before and after value of a field, such as an ID3 frame.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Action is a single mutation that a command would have made.
+type Action struct {
+	Kind    Kind
+	Target  string
+	Details map[string]string `json:",omitempty"`
+	Changes []Change          `json:",omitempty"`
+}
+
+// Plan collects the actions a command would perform when running in dry-run mode.
+type Plan struct {
+	mu      sync.Mutex
+	Actions []Action
+}
+
+// Add records an action on the plan. It is safe to call from multiple goroutines.
+func (plan *Plan) Add(action Action) {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	plan.Actions = append(plan.Actions, action)
+}
+
+// Write prints the plan in a human readable form, grouped by kind.
+func (plan *Plan) Write(w io.Writer) error {
+	actions := plan.sorted()
+	_, err := fmt.Fprintf(w, "Plan: %d actions (dry run, nothing was changed)\n", len(actions))
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		_, err = fmt.Fprintf(w, "%s %s\n", action.Kind, action.Target)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(action.Details))
+		for key := range action.Details {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			_, err = fmt.Fprintf(w, "    %s: %s\n", key, action.Details[key])
+			if err != nil {
+				return err
+			}
+		}
+		for _, change := range action.Changes {
+			_, err = fmt.Fprintf(w, "    %s: %q -> %q\n", strings.ToLower(change.Field), change.Old, change.New)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON prints the plan as a JSON document, grouped by kind.
+func (plan *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct{ Actions []Action }{plan.sorted()})
+}
+
+// sorted returns a copy of the actions ordered by kind and then target.
+func (plan *Plan) sorted() []Action {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	actions := make([]Action, len(plan.Actions))
+	copy(actions, plan.Actions)
+	sort.SliceStable(actions, func(i, j int) bool {
+		if actions[i].Kind != actions[j].Kind {
+			return actions[i].Kind < actions[j].Kind
+		}
+		return actions[i].Target < actions[j].Target
+	})
+	return actions
+}
+
+type contextKey int
+
+const planKey contextKey = iota
+
+// ContextWithPlan returns a new context in dry-run mode, with an empty plan to record actions on.
+func ContextWithPlan(ctx context.Context) context.Context {
+	return context.WithValue(ctx, planKey, &Plan{})
+}
+
+// ContextPlan returns the plan for the supplied context, or nil if the context is not a dry run.
+func ContextPlan(ctx context.Context) *Plan {
+	val := ctx.Value(planKey)
+	if val != nil {
+		return val.(*Plan)
+	}
+	return nil
+}
+
+// DryRun returns true if mutations should be recorded on a plan instead of performed.
+func DryRun(ctx context.Context) bool {
+	return ContextPlan(ctx) != nil
+}
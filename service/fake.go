@@ -0,0 +1,277 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zmb3/spotify"
+)
+
+// Fake is an in-memory Service, for running commands without a Spotify account. Its fields can be set up directly
+// before use; every method is safe to call from multiple goroutines.
+type Fake struct {
+	mu     sync.Mutex
+	UserID string
+	// PlaylistList are the user's playlists, and PlaylistTrackIDs the tracks in each, in order.
+	PlaylistList     []spotify.SimplePlaylist
+	PlaylistTrackIDs map[spotify.ID][]spotify.ID
+	// Tracks, AlbumsByID and Artists are the catalog available to search and look up.
+	Tracks     map[spotify.ID]spotify.FullTrack
+	AlbumsByID map[spotify.ID]*spotify.FullAlbum
+	Artists    []spotify.FullArtist
+	// Following are the IDs of the followed artists.
+	Following map[spotify.ID]bool
+	nextID    int
+}
+
+// NewFake returns an empty fake for the supplied user.
+func NewFake(userID string) *Fake {
+	return &Fake{
+		UserID:           userID,
+		PlaylistTrackIDs: map[spotify.ID][]spotify.ID{},
+		Tracks:           map[spotify.ID]spotify.FullTrack{},
+		AlbumsByID:       map[spotify.ID]*spotify.FullAlbum{},
+		Following:        map[spotify.ID]bool{},
+	}
+}
+
+// AddPlaylist adds a playlist of the supplied tracks, adding the tracks to the catalog.
+func (f *Fake) AddPlaylist(name string, tracks ...spotify.FullTrack) spotify.SimplePlaylist {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	playlist := spotify.SimplePlaylist{ID: f.newID("playlist"), Name: name}
+	playlist.Owner.ID = f.UserID
+	f.PlaylistList = append(f.PlaylistList, playlist)
+	for _, track := range tracks {
+		f.Tracks[track.ID] = track
+		f.PlaylistTrackIDs[playlist.ID] = append(f.PlaylistTrackIDs[playlist.ID], track.ID)
+	}
+	return playlist
+}
+
+func (f *Fake) newID(kind string) spotify.ID {
+	f.nextID++
+	return spotify.ID(fmt.Sprintf("%s%d", kind, f.nextID))
+}
+
+func (f *Fake) CurrentUser() (string, error) {
+	return f.UserID, nil
+}
+
+func (f *Fake) Playlists() ([]spotify.SimplePlaylist, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]spotify.SimplePlaylist{}, f.PlaylistList...), nil
+}
+
+func (f *Fake) PlaylistTracks(userID string, playlistID spotify.ID) ([]spotify.FullTrack, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids, ok := f.PlaylistTrackIDs[playlistID]
+	if !ok && !f.hasPlaylist(playlistID) {
+		return nil, fmt.Errorf("fake: unknown playlist %s", playlistID)
+	}
+	tracks := make([]spotify.FullTrack, len(ids))
+	for i, id := range ids {
+		tracks[i] = f.Tracks[id]
+		// Tracks added by ID needn't be in the catalog.
+		tracks[i].ID = id
+	}
+	return tracks, nil
+}
+
+func (f *Fake) PlaylistLength(userID string, playlistID spotify.ID) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.hasPlaylist(playlistID) {
+		return 0, fmt.Errorf("fake: unknown playlist %s", playlistID)
+	}
+	return len(f.PlaylistTrackIDs[playlistID]), nil
+}
+
+func (f *Fake) hasPlaylist(playlistID spotify.ID) bool {
+	for _, playlist := range f.PlaylistList {
+		if playlist.ID == playlistID {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fake) CreatePlaylist(userID, name string) (spotify.ID, error) {
+	return f.AddPlaylist(name).ID, nil
+}
+
+func (f *Fake) AddPlaylistTracks(userID string, playlistID spotify.ID, trackIDs ...spotify.ID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.hasPlaylist(playlistID) {
+		return fmt.Errorf("fake: unknown playlist %s", playlistID)
+	}
+	f.PlaylistTrackIDs[playlistID] = append(f.PlaylistTrackIDs[playlistID], trackIDs...)
+	return nil
+}
+
+func (f *Fake) RemovePlaylistTracks(userID string, playlistID spotify.ID, trackIDs ...spotify.ID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	remove := map[spotify.ID]bool{}
+	for _, id := range trackIDs {
+		remove[id] = true
+	}
+	kept := []spotify.ID{}
+	for _, id := range f.PlaylistTrackIDs[playlistID] {
+		if !remove[id] {
+			kept = append(kept, id)
+		}
+	}
+	f.PlaylistTrackIDs[playlistID] = kept
+	return nil
+}
+
+func (f *Fake) ClearPlaylist(userID string, playlistID spotify.ID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.PlaylistTrackIDs[playlistID] = nil
+	return nil
+}
+
+func (f *Fake) MovePlaylistTrack(userID string, playlistID spotify.ID, from, before int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := f.PlaylistTrackIDs[playlistID]
+	if from < 0 || from >= len(ids) || before < 0 || before > len(ids) {
+		return fmt.Errorf("fake: can't move %d before %d in a playlist of %d tracks", from, before, len(ids))
+	}
+	moved := ids[from]
+	rest := append(append([]spotify.ID{}, ids[:from]...), ids[from+1:]...)
+	if before > from {
+		before--
+	}
+	f.PlaylistTrackIDs[playlistID] = append(append(append([]spotify.ID{}, rest[:before]...), moved), rest[before:]...)
+	return nil
+}
+
+// searchFieldRegex matches the field filters of a search query, such as `track:"Song"`.
+var searchFieldRegex = regexp.MustCompile(`(\w+):"([^"]*)"`)
+
+// SearchTracks returns the catalog tracks whose name and artists contain the track and artist filters of the query,
+// ignoring case. Words outside a filter must be in the name or an artist.
+func (f *Fake) SearchTracks(query string, limit int) ([]spotify.FullTrack, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filters := map[string]string{}
+	for _, match := range searchFieldRegex.FindAllStringSubmatch(query, -1) {
+		filters[match[1]] = strings.ToLower(match[2])
+	}
+	words := strings.Fields(strings.ToLower(searchFieldRegex.ReplaceAllString(query, "")))
+	tracks := []spotify.FullTrack{}
+	for _, track := range f.Tracks {
+		name := strings.ToLower(track.Name)
+		artists := []string{}
+		for _, artist := range track.Artists {
+			artists = append(artists, strings.ToLower(artist.Name))
+		}
+		all := name + " " + strings.Join(artists, " ")
+		if !strings.Contains(name, filters["track"]) || !strings.Contains(strings.Join(artists, " "), filters["artist"]) {
+			continue
+		}
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(all, word) {
+				matched = false
+			}
+		}
+		if matched {
+			tracks = append(tracks, track)
+		}
+	}
+	// Map order is random, so order results by ID to keep searches repeatable.
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].ID < tracks[j].ID
+	})
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks, nil
+}
+
+func (f *Fake) Albums(ids ...spotify.ID) ([]*spotify.FullAlbum, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	albums := make([]*spotify.FullAlbum, len(ids))
+	for i, id := range ids {
+		albums[i] = f.AlbumsByID[id]
+	}
+	return albums, nil
+}
+
+func (f *Fake) AlbumTracks(albumID spotify.ID) ([]spotify.SimpleTrack, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	album, ok := f.AlbumsByID[albumID]
+	if !ok || album == nil {
+		return nil, fmt.Errorf("fake: unknown album %s", albumID)
+	}
+	return append([]spotify.SimpleTrack{}, album.Tracks.Tracks...), nil
+}
+
+func (f *Fake) ArtistAlbums(artistID spotify.ID) ([]spotify.SimpleAlbum, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	albums := []spotify.SimpleAlbum{}
+	for _, album := range f.AlbumsByID {
+		for _, artist := range album.Artists {
+			if artist.ID == artistID {
+				albums = append(albums, album.SimpleAlbum)
+				break
+			}
+		}
+	}
+	return albums, nil
+}
+
+func (f *Fake) SearchArtists(name string) ([]spotify.FullArtist, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	artists := []spotify.FullArtist{}
+	for _, artist := range f.Artists {
+		if strings.Contains(strings.ToLower(artist.Name), strings.ToLower(name)) {
+			artists = append(artists, artist)
+		}
+	}
+	return artists, nil
+}
+
+func (f *Fake) FollowedArtists() ([]spotify.FullArtist, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	artists := []spotify.FullArtist{}
+	for _, artist := range f.Artists {
+		if f.Following[artist.ID] {
+			artists = append(artists, artist)
+		}
+	}
+	return artists, nil
+}
+
+func (f *Fake) FollowArtists(ids ...spotify.ID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		f.Following[id] = true
+	}
+	return nil
+}
+
+func (f *Fake) UnfollowArtists(ids ...spotify.ID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		delete(f.Following, id)
+	}
+	return nil
+}
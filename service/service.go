@@ -0,0 +1,66 @@
+// Package service abstracts the music services musicmanager talks to, so commands can run against Spotify or an
+// in-memory fake. The interface uses the github.com/zmb3/spotify types for tracks, playlists, albums and artists.
+package service
+
+import (
+	"context"
+
+	"github.com/zmb3/spotify"
+)
+
+// Service is a catalog of tracks, playlists and artists, such as Spotify. Tracks, playlists, albums and artists use
+// the Spotify types, as they're what the playlist graph is cached as.
+type Service interface {
+	// CurrentUser returns the ID of the authenticated user.
+	CurrentUser() (string, error)
+	// Playlists returns every playlist of the authenticated user.
+	Playlists() ([]spotify.SimplePlaylist, error)
+	// PlaylistTracks returns the tracks of a playlist, in playlist order.
+	PlaylistTracks(userID string, playlistID spotify.ID) ([]spotify.FullTrack, error)
+	// PlaylistLength returns the number of tracks in a playlist, without fetching them.
+	PlaylistLength(userID string, playlistID spotify.ID) (int, error)
+	// CreatePlaylist creates a private playlist, returning its ID.
+	CreatePlaylist(userID, name string) (spotify.ID, error)
+	// AddPlaylistTracks appends the tracks to a playlist.
+	AddPlaylistTracks(userID string, playlistID spotify.ID, trackIDs ...spotify.ID) error
+	// RemovePlaylistTracks removes every occurrence of the tracks from a playlist.
+	RemovePlaylistTracks(userID string, playlistID spotify.ID, trackIDs ...spotify.ID) error
+	// ClearPlaylist removes every track from a playlist.
+	ClearPlaylist(userID string, playlistID spotify.ID) error
+	// MovePlaylistTrack moves the track at one index of a playlist to before another index.
+	MovePlaylistTrack(userID string, playlistID spotify.ID, from, before int) error
+	// SearchTracks returns up to limit tracks matching a query such as `track:"Song" artist:"Artist"`.
+	SearchTracks(query string, limit int) ([]spotify.FullTrack, error)
+	// Albums returns the full albums with the supplied IDs, with nil for any that couldn't be found.
+	Albums(ids ...spotify.ID) ([]*spotify.FullAlbum, error)
+	// AlbumTracks returns every track of an album, which can be more than the first page included in a full album.
+	AlbumTracks(albumID spotify.ID) ([]spotify.SimpleTrack, error)
+	// ArtistAlbums returns the albums and singles of an artist.
+	ArtistAlbums(artistID spotify.ID) ([]spotify.SimpleAlbum, error)
+	// SearchArtists returns the artists matching a name, best match first.
+	SearchArtists(name string) ([]spotify.FullArtist, error)
+	// FollowedArtists returns every artist the authenticated user follows.
+	FollowedArtists() ([]spotify.FullArtist, error)
+	// FollowArtists follows the artists as the authenticated user.
+	FollowArtists(ids ...spotify.ID) error
+	// UnfollowArtists stops following the artists as the authenticated user.
+	UnfollowArtists(ids ...spotify.ID) error
+}
+
+type contextKey int
+
+const serviceKey contextKey = iota
+
+// ContextWithService returns a new context that uses the supplied service.
+func ContextWithService(ctx context.Context, service Service) context.Context {
+	return context.WithValue(ctx, serviceKey, service)
+}
+
+// ContextService returns the service for the supplied context, or nil if there isn't one.
+func ContextService(ctx context.Context) Service {
+	val := ctx.Value(serviceKey)
+	if val != nil {
+		return val.(Service)
+	}
+	return nil
+}
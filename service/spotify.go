@@ -0,0 +1,215 @@
+package service
+
+import (
+	"github.com/snikch/api/fail"
+	"github.com/zmb3/spotify"
+)
+
+const (
+	// pageLimit is the most items Spotify returns in a page.
+	pageLimit = 50
+	// playlistBatch, albumBatch and followBatch are the most items Spotify accepts in a single request.
+	playlistBatch = 100
+	albumBatch    = 20
+	followBatch   = 50
+)
+
+// Spotify is the Spotify Web API implementation of Service. Paging and request size limits are handled here, so
+// callers can pass any number of IDs.
+type Spotify struct {
+	client *spotify.Client
+}
+
+// NewSpotify returns a service using the supplied authenticated client.
+func NewSpotify(client *spotify.Client) *Spotify {
+	return &Spotify{client: client}
+}
+
+func (s *Spotify) CurrentUser() (string, error) {
+	user, err := s.client.CurrentUser()
+	if err != nil {
+		return "", fail.Trace(err)
+	}
+	return user.ID, nil
+}
+
+func (s *Spotify) Playlists() ([]spotify.SimplePlaylist, error) {
+	playlists := []spotify.SimplePlaylist{}
+	limit := pageLimit
+	for offset := 0; ; {
+		page, err := s.client.CurrentUsersPlaylistsOpt(&spotify.Options{Limit: &limit, Offset: &offset})
+		if err != nil {
+			return nil, fail.Trace(err)
+		}
+		playlists = append(playlists, page.Playlists...)
+		offset += len(page.Playlists)
+		if len(page.Playlists) == 0 || offset >= page.Total {
+			return playlists, nil
+		}
+	}
+}
+
+func (s *Spotify) PlaylistTracks(userID string, playlistID spotify.ID) ([]spotify.FullTrack, error) {
+	tracks := []spotify.FullTrack{}
+	limit := pageLimit
+	for offset := 0; ; {
+		page, err := s.client.GetPlaylistTracksOpt(userID, playlistID, &spotify.Options{Limit: &limit, Offset: &offset}, "")
+		if err != nil {
+			return nil, fail.Trace(err)
+		}
+		for _, track := range page.Tracks {
+			tracks = append(tracks, track.Track)
+		}
+		offset += len(page.Tracks)
+		if len(page.Tracks) == 0 || offset >= page.Total {
+			return tracks, nil
+		}
+	}
+}
+
+func (s *Spotify) PlaylistLength(userID string, playlistID spotify.ID) (int, error) {
+	limit := 1
+	page, err := s.client.GetPlaylistTracksOpt(userID, playlistID, &spotify.Options{Limit: &limit}, "")
+	if err != nil {
+		return 0, fail.Trace(err)
+	}
+	return page.Total, nil
+}
+
+func (s *Spotify) CreatePlaylist(userID, name string) (spotify.ID, error) {
+	playlist, err := s.client.CreatePlaylistForUser(userID, name, false)
+	if err != nil {
+		return "", fail.Trace(err)
+	}
+	return playlist.ID, nil
+}
+
+func (s *Spotify) AddPlaylistTracks(userID string, playlistID spotify.ID, trackIDs ...spotify.ID) error {
+	return batch(trackIDs, playlistBatch, func(ids ...spotify.ID) error {
+		_, err := s.client.AddTracksToPlaylist(userID, playlistID, ids...)
+		return err
+	})
+}
+
+func (s *Spotify) RemovePlaylistTracks(userID string, playlistID spotify.ID, trackIDs ...spotify.ID) error {
+	return batch(trackIDs, playlistBatch, func(ids ...spotify.ID) error {
+		_, err := s.client.RemoveTracksFromPlaylist(userID, playlistID, ids...)
+		return err
+	})
+}
+
+func (s *Spotify) ClearPlaylist(userID string, playlistID spotify.ID) error {
+	return fail.Trace(s.client.ReplacePlaylistTracks(userID, playlistID))
+}
+
+func (s *Spotify) MovePlaylistTrack(userID string, playlistID spotify.ID, from, before int) error {
+	_, err := s.client.ReorderPlaylistTracks(userID, playlistID, spotify.PlaylistReorderOptions{
+		RangeStart:   from,
+		RangeLength:  1,
+		InsertBefore: before,
+	})
+	return fail.Trace(err)
+}
+
+func (s *Spotify) SearchTracks(query string, limit int) ([]spotify.FullTrack, error) {
+	result, err := s.client.SearchOpt(query, spotify.SearchTypeTrack, &spotify.Options{Limit: &limit})
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	if result.Tracks == nil {
+		return nil, nil
+	}
+	return result.Tracks.Tracks, nil
+}
+
+func (s *Spotify) Albums(ids ...spotify.ID) ([]*spotify.FullAlbum, error) {
+	albums := make([]*spotify.FullAlbum, 0, len(ids))
+	err := batch(ids, albumBatch, func(ids ...spotify.ID) error {
+		chunk, err := s.client.GetAlbums(ids...)
+		albums = append(albums, chunk...)
+		return err
+	})
+	return albums, err
+}
+
+func (s *Spotify) AlbumTracks(albumID spotify.ID) ([]spotify.SimpleTrack, error) {
+	tracks := []spotify.SimpleTrack{}
+	for offset := 0; ; {
+		page, err := s.client.GetAlbumTracksOpt(albumID, pageLimit, offset)
+		if err != nil {
+			return nil, fail.Trace(err)
+		}
+		tracks = append(tracks, page.Tracks...)
+		offset += len(page.Tracks)
+		if len(page.Tracks) == 0 || offset >= page.Total {
+			return tracks, nil
+		}
+	}
+}
+
+func (s *Spotify) ArtistAlbums(artistID spotify.ID) ([]spotify.SimpleAlbum, error) {
+	albums := []spotify.SimpleAlbum{}
+	limit := pageLimit
+	albumTypes := spotify.AlbumTypeAlbum | spotify.AlbumTypeSingle
+	for offset := 0; ; {
+		page, err := s.client.GetArtistAlbumsOpt(artistID, &spotify.Options{Limit: &limit, Offset: &offset}, &albumTypes)
+		if err != nil {
+			return nil, fail.Trace(err)
+		}
+		albums = append(albums, page.Albums...)
+		offset += len(page.Albums)
+		if len(page.Albums) < limit {
+			return albums, nil
+		}
+	}
+}
+
+func (s *Spotify) SearchArtists(name string) ([]spotify.FullArtist, error) {
+	result, err := s.client.Search(name, spotify.SearchTypeArtist)
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	if result.Artists == nil {
+		return nil, nil
+	}
+	return result.Artists.Artists, nil
+}
+
+func (s *Spotify) FollowedArtists() ([]spotify.FullArtist, error) {
+	artists := []spotify.FullArtist{}
+	after := ""
+	for {
+		chunk, err := s.client.CurrentUsersFollowedArtistsOpt(pageLimit, after)
+		if err != nil {
+			return nil, fail.Trace(err)
+		}
+		artists = append(artists, chunk.Artists...)
+		if len(chunk.Artists) < pageLimit {
+			return artists, nil
+		}
+		after = chunk.Cursor.After
+	}
+}
+
+func (s *Spotify) FollowArtists(ids ...spotify.ID) error {
+	return batch(ids, followBatch, s.client.FollowArtist)
+}
+
+func (s *Spotify) UnfollowArtists(ids ...spotify.ID) error {
+	return batch(ids, followBatch, s.client.UnfollowArtist)
+}
+
+// batch calls fn with successive slices of at most size IDs.
+func batch(ids []spotify.ID, size int, fn func(...spotify.ID) error) error {
+	for head := 0; head < len(ids); head += size {
+		tail := head + size
+		if tail > len(ids) {
+			tail = len(ids)
+		}
+		err := fn(ids[head:tail]...)
+		if err != nil {
+			return fail.Trace(err)
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package spotify
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"regexp"
@@ -12,7 +13,7 @@ import (
 	"github.com/snikch/api/fail"
 	"github.com/snikch/api/log"
 	"github.com/snikch/musicmanager/configuration"
-	"github.com/snikch/musicmanager/spotifyclient"
+	"github.com/snikch/musicmanager/service"
 	"github.com/snikch/musicmanager/types"
 	"github.com/zmb3/spotify"
 )
@@ -21,16 +22,23 @@ const (
 	playlistCacheLoc = ".cache/playlists"
 )
 
-var (
-	limit = 50
-)
-
 type TrackLookup map[types.SongKey]spotify.FullTrack
 type PlaylistLookup map[types.SongKey][]spotify.SimplePlaylist
 type TrackGraph struct {
 	UserID    string
 	Tracks    TrackLookup
 	Playlists PlaylistLookup
+	// Recordings are the recordings of each track, with the mix name parsed from the title.
+	Recordings map[types.SongKey]types.RecordingKey `json:",omitempty"`
+}
+
+// Recording returns the recording of the track with the supplied key, parsing it from the title if the graph was
+// cached before recordings were.
+func (graph TrackGraph) Recording(key types.SongKey) types.RecordingKey {
+	if recording, ok := graph.Recordings[key]; ok {
+		return recording
+	}
+	return key.Recording()
 }
 
 func CreateGraphCache(ctx context.Context) error {
@@ -78,14 +86,17 @@ func writeGraphToCache(ctx context.Context, graph TrackGraph) error {
 
 func retrieveGraph(ctx context.Context) (TrackGraph, error) {
 	graph := TrackGraph{
-		Playlists: PlaylistLookup{},
-		Tracks:    TrackLookup{},
+		Playlists:  PlaylistLookup{},
+		Tracks:     TrackLookup{},
+		Recordings: map[types.SongKey]types.RecordingKey{},
 	}
-	client := spotifyclient.ContextClient(ctx)
-	opts := &spotify.Options{Limit: &limit}
-	playlists, err := client.CurrentUsersPlaylistsOpt(opts)
+	svc := service.ContextService(ctx)
+	if svc == nil {
+		return graph, errors.New("no Spotify playlist cache, run refresh-spotify first")
+	}
+	playlists, err := svc.Playlists()
 	if err != nil {
-		return graph, fail.Trace(err)
+		return graph, err
 	}
 	conf := configuration.ContextConfiguration(ctx)
 	log.WithField("regex", conf.Spotify.PlaylistRegex).Debug("Matching playlists")
@@ -101,7 +112,7 @@ func retrieveGraph(ctx context.Context) (TrackGraph, error) {
 	ch := make(chan result)
 	wg := &sync.WaitGroup{}
 	wg2 := &sync.WaitGroup{}
-	for _, playlist := range playlists.Playlists {
+	for _, playlist := range playlists {
 		wg.Add(1)
 		go func(ch chan<- result, wg, wg2 *sync.WaitGroup, playlist spotify.SimplePlaylist) {
 			defer wg.Done()
@@ -111,37 +122,19 @@ func retrieveGraph(ctx context.Context) (TrackGraph, error) {
 				return
 			}
 			l.Info("Processing Playlist")
-			var fullList *spotify.PlaylistTrackPage
-			for {
-				if fullList != nil {
-					offset := fullList.Offset + len(fullList.Tracks)
-					opts.Offset = &offset
-				} else {
-					offset := 0
-					opts.Offset = &offset
-				}
-				userID := playlist.Owner.ID
-				fullList, err = client.GetPlaylistTracksOpt(playlist.Owner.ID, playlist.ID, opts, "")
-				if err != nil {
-					log.WithError(fail.Trace(err)).Fatal()
-				}
-				if len(fullList.Tracks) == 0 {
-					break
-				}
-
-				l.WithField("tracks", len(fullList.Tracks)).
-					WithField("offset", *opts.Offset).
-					Info("Received spotify playlist tracks")
-				tracks := []spotify.FullTrack{}
-				for _, track := range fullList.Tracks {
-					tracks = append(tracks, track.Track)
-				}
-				wg2.Add(1)
-				ch <- result{
-					UserID:   userID,
-					Playlist: playlist,
-					Tracks:   tracks,
-				}
+			tracks, err := svc.PlaylistTracks(playlist.Owner.ID, playlist.ID)
+			if err != nil {
+				log.WithError(err).Fatal()
+			}
+			l.WithField("tracks", len(tracks)).Info("Received spotify playlist tracks")
+			if len(tracks) == 0 {
+				return
+			}
+			wg2.Add(1)
+			ch <- result{
+				UserID:   playlist.Owner.ID,
+				Playlist: playlist,
+				Tracks:   tracks,
 			}
 		}(ch, wg, wg2, playlist)
 	}
@@ -156,8 +149,10 @@ func retrieveGraph(ctx context.Context) (TrackGraph, error) {
 					Artist: strings.Join(artistParts, ", "),
 					Title:  track.Name,
 				}
-				log.WithField("key", key).Debug("Spotify track")
+				recording := key.Recording()
+				log.WithField("key", key).WithField("recording", recording).Debug("Spotify track")
 				graph.Tracks[key] = track
+				graph.Recordings[key] = recording
 				graph.Playlists[key] = append(graph.Playlists[key], result.Playlist)
 				graph.UserID = result.UserID
 			}
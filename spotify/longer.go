@@ -0,0 +1,93 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snikch/api/log"
+	"github.com/snikch/musicmanager/service"
+	"github.com/snikch/musicmanager/types"
+	"github.com/zmb3/spotify"
+)
+
+const (
+	// longerTolerance is how much longer (in ms) a version must be to be considered a different mix.
+	longerTolerance = 15000
+	searchLimit     = 20
+)
+
+var (
+	// LongerMixNames are the mix names that usually denote a longer version of a track.
+	LongerMixNames = []string{"Extended Mix", "Original Mix", "Club Mix"}
+
+	artistSplitRegex = regexp.MustCompile(`(?i)\s*(,|&| feat\.? | ft\.? | featuring | x | vs\.? )\s*`)
+)
+
+// FindLongerVersion searches Spotify for an extended, original or club mix of the song that is longer than the
+// supplied duration. It returns nil if no longer version could be found.
+func FindLongerVersion(ctx context.Context, key types.SongKey, duration int) (*spotify.FullTrack, error) {
+	title := types.BaseTitle(key.Title)
+	artist := primaryArtist(key.Artist)
+	results, err := service.ContextService(ctx).SearchTracks(searchQuery(title, artist), searchLimit)
+	if err != nil {
+		return nil, err
+	}
+	var longest *spotify.FullTrack
+	for i := range results {
+		track := results[i]
+		l := log.WithField("key", key).WithField("candidate", track.Name)
+		if !strings.EqualFold(types.BaseTitle(track.Name), title) {
+			l.Debug("Skipping candidate with different title")
+			continue
+		}
+		if !hasArtist(track.Artists, artist) {
+			l.Debug("Skipping candidate with different artist")
+			continue
+		}
+		if !isLongerMix(track.Name) {
+			l.Debug("Skipping candidate that isn't a longer mix")
+			continue
+		}
+		if track.Duration <= duration+longerTolerance {
+			l.WithField("duration", track.Duration).Debug("Skipping candidate that isn't longer")
+			continue
+		}
+		if longest == nil || track.Duration > longest.Duration {
+			longest = &track
+		}
+	}
+	return longest, nil
+}
+
+// searchQuery returns a search for the title by the artist. Spotify has no way to escape quotes within a field, so
+// they're removed.
+func searchQuery(title, artist string) string {
+	unquote := strings.NewReplacer(`"`, "", "“", "", "”", "")
+	return fmt.Sprintf(`track:"%s" artist:"%s"`, unquote.Replace(title), unquote.Replace(artist))
+}
+
+// primaryArtist returns the first artist of a joined artist string, e.g. "A, B" and "A feat. B" become "A".
+func primaryArtist(artist string) string {
+	return strings.TrimSpace(artistSplitRegex.Split(artist, 2)[0])
+}
+
+func hasArtist(artists []spotify.SimpleArtist, name string) bool {
+	for _, artist := range artists {
+		if strings.EqualFold(artist.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLongerMix(name string) bool {
+	name = strings.ToLower(name)
+	for _, mix := range LongerMixNames {
+		if strings.Contains(name, strings.ToLower(mix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package spotify
+
+import "testing"
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		title, artist string
+		expected      string
+	}{
+		{"Song", "Artist", `track:"Song" artist:"Artist"`},
+		{`12" Song`, `The "Artist"`, `track:"12 Song" artist:"The Artist"`},
+		{"“Song”", "Artist", `track:"Song" artist:"Artist"`},
+	}
+	for _, test := range tests {
+		if actual := searchQuery(test.title, test.artist); actual != test.expected {
+			t.Errorf("searchQuery(%q, %q): expected %s, got %s", test.title, test.artist, test.expected, actual)
+		}
+	}
+}
+
+func TestPrimaryArtist(t *testing.T) {
+	tests := []struct {
+		artist   string
+		expected string
+	}{
+		{"Artist", "Artist"},
+		{"A, B", "A"},
+		{"A & B", "A"},
+		{"A feat. B", "A"},
+		{"A ft B", "A"},
+		{"A featuring B", "A"},
+		{"A x B", "A"},
+		{"A vs. B", "A"},
+		{"Axel", "Axel"},
+		{" Artist ", "Artist"},
+	}
+	for _, test := range tests {
+		if actual := primaryArtist(test.artist); actual != test.expected {
+			t.Errorf("primaryArtist(%q): expected %q, got %q", test.artist, test.expected, actual)
+		}
+	}
+}
+
+func TestIsLongerMix(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"Song (Extended Mix)", true},
+		{"Song - Original Mix", true},
+		{"Song [CLUB MIX]", true},
+		{"Song", false},
+		{"Song - Radio Edit", false},
+		{"Song (Someone Remix)", false},
+		{"Song (Extended)", false},
+	}
+	for _, test := range tests {
+		if actual := isLongerMix(test.name); actual != test.expected {
+			t.Errorf("isLongerMix(%q): expected %t, got %t", test.name, test.expected, actual)
+		}
+	}
+}
@@ -7,32 +7,68 @@ import (
 	"github.com/snikch/api/fail"
 	"github.com/snikch/api/log"
 	"github.com/snikch/musicmanager/configuration"
-	"github.com/snikch/musicmanager/spotifyclient"
+	"github.com/snikch/musicmanager/plan"
+	"github.com/snikch/musicmanager/service"
 	"github.com/zmb3/spotify"
 )
 
-func CreateMissingPlaylist(ctx context.Context, graph TrackGraph, tracks TrackLookup) error {
+// CreateMissingPlaylist replaces the contents of the configured output playlist with the supplied tracks, or of the
+// playlist called name if it's set.
+func CreateMissingPlaylist(ctx context.Context, graph TrackGraph, tracks TrackLookup, name string) error {
+	conf := configuration.ContextConfiguration(ctx)
+	output, err := NamedPlaylist(ctx, &conf.Spotify.OutputPlaylist, name)
+	if err != nil {
+		return err
+	}
+	return FillPlaylist(ctx, graph, output, tracks)
+}
+
+// NamedPlaylist returns the configured playlist, unless name is set and differs from its name. The user's playlist
+// with that name is returned instead, or a new one without an ID if there isn't one. Overridden playlists aren't part
+// of the configuration, so the ID of one created for this run isn't persisted in place of the configured playlist.
+func NamedPlaylist(ctx context.Context, configured *configuration.Playlist, name string) (*configuration.Playlist, error) {
+	if name == "" || name == configured.Name {
+		return configured, nil
+	}
+	playlists, err := service.ContextService(ctx).Playlists()
+	if err != nil {
+		return nil, err
+	}
+	for _, playlist := range playlists {
+		if playlist.Name == name {
+			return &configuration.Playlist{ID: string(playlist.ID), Name: name}, nil
+		}
+	}
+	return &configuration.Playlist{Name: name}, nil
+}
+
+// FillPlaylist replaces the contents of the supplied playlist with the tracks, creating the playlist if it has no
+// ID yet. The ID of a newly created playlist is set on the supplied playlist so it's persisted with the configuration.
+func FillPlaylist(ctx context.Context, graph TrackGraph, output *configuration.Playlist, tracks TrackLookup) error {
 	if len(tracks) == 0 {
 		log.Info("Nothing to do")
 		return nil
 	}
-	conf := configuration.ContextConfiguration(ctx)
-	client := spotifyclient.ContextClient(ctx)
-	playlistID := spotify.ID(conf.Spotify.OutputPlaylist.ID)
+	if p := plan.ContextPlan(ctx); p != nil {
+		planPlaylist(p, output, graph, tracks)
+		return nil
+	}
+	svc := service.ContextService(ctx)
+	playlistID := spotify.ID(output.ID)
 	if playlistID == "" {
-		playlist, err := client.CreatePlaylistForUser(graph.UserID, conf.Spotify.OutputPlaylist.Name, false)
+		var err error
+		playlistID, err = svc.CreatePlaylist(graph.UserID, output.Name)
 		if err != nil {
-			return fail.Trace(err)
+			return err
 		}
 		log.
 			WithField("user", graph.UserID).
-			WithField("name", conf.Spotify.OutputPlaylist.Name).
-			Info("Created new spotify missing playlist")
-		playlistID = playlist.ID
-		conf.Spotify.OutputPlaylist.ID = string(playlistID)
+			WithField("name", output.Name).
+			Info("Created new spotify playlist")
+		output.ID = string(playlistID)
 	} else {
-		log.WithField("id", playlistID).Info("Clearing existing spotify missing playlist")
-		err := client.ReplacePlaylistTracks(graph.UserID, playlistID)
+		log.WithField("id", playlistID).WithField("name", output.Name).Info("Clearing existing spotify playlist")
+		err := svc.ClearPlaylist(graph.UserID, playlistID)
 		if err != nil {
 			log.WithError(fail.Trace(err)).Fatal()
 		}
@@ -58,18 +94,18 @@ func CreateMissingPlaylist(ctx context.Context, graph TrackGraph, tracks TrackLo
 			Info("Adding track to playlist")
 		if count >= 100 {
 			log.Debug("Saving playlist")
-			_, err := client.AddTracksToPlaylist(graph.UserID, playlistID, batch...)
+			err := svc.AddPlaylistTracks(graph.UserID, playlistID, batch...)
 			if err != nil {
-				return fail.Trace(err)
+				return err
 			}
 			batch = []spotify.ID{}
 			count = 0
 		}
 	}
 	if len(batch) > 0 {
-		_, err := client.AddTracksToPlaylist(graph.UserID, playlistID, batch...)
+		err := svc.AddPlaylistTracks(graph.UserID, playlistID, batch...)
 		if err != nil {
-			return fail.Trace(err)
+			return err
 		}
 	}
 	log.WithField("id", playlistID).
@@ -79,6 +115,37 @@ func CreateMissingPlaylist(ctx context.Context, graph TrackGraph, tracks TrackLo
 	return nil
 }
 
+// planPlaylist records the changes FillPlaylist would make to the playlist.
+func planPlaylist(p *plan.Plan, output *configuration.Playlist, graph TrackGraph, tracks TrackLookup) {
+	if output.ID == "" {
+		p.Add(plan.Action{
+			Kind:    plan.KindPlaylistCreate,
+			Target:  output.Name,
+			Details: map[string]string{"user": graph.UserID},
+		})
+	} else {
+		p.Add(plan.Action{
+			Kind:    plan.KindPlaylistClear,
+			Target:  output.Name,
+			Details: map[string]string{"id": output.ID},
+		})
+	}
+	for key, track := range tracks {
+		if track.ID.String() == "" {
+			continue
+		}
+		p.Add(plan.Action{
+			Kind:   plan.KindPlaylistAdd,
+			Target: strings.Join(artistNames(track.Artists), ", ") + " - " + track.Name,
+			Details: map[string]string{
+				"playlist":  output.Name,
+				"trackID":   track.ID.String(),
+				"playlists": strings.Join(playlistNames(graph.Playlists[key]), ", "),
+			},
+		})
+	}
+}
+
 func artistNames(artists []spotify.SimpleArtist) []string {
 	names := make([]string, len(artists))
 	for i := range artists {
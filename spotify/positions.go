@@ -0,0 +1,40 @@
+package spotify
+
+import (
+	"context"
+
+	"github.com/snikch/musicmanager/service"
+	"github.com/zmb3/spotify"
+)
+
+// PlaylistPositions returns the indexes of every track in a playlist, keyed by track ID.
+func PlaylistPositions(ctx context.Context, userID string, playlistID spotify.ID) (map[spotify.ID][]int, error) {
+	tracks, err := service.ContextService(ctx).PlaylistTracks(userID, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	positions := map[spotify.ID][]int{}
+	for i, track := range tracks {
+		positions[track.ID] = append(positions[track.ID], i)
+	}
+	return positions, nil
+}
+
+// InsertTrack adds a track to a playlist at the supplied position. Tracks can only be appended, so the track is
+// added to the end and then moved into place.
+func InsertTrack(ctx context.Context, userID string, playlistID, trackID spotify.ID, position int) error {
+	svc := service.ContextService(ctx)
+	err := svc.AddPlaylistTracks(userID, playlistID, trackID)
+	if err != nil {
+		return err
+	}
+	length, err := svc.PlaylistLength(userID, playlistID)
+	if err != nil {
+		return err
+	}
+	last := length - 1
+	if position >= last {
+		return nil
+	}
+	return svc.MovePlaylistTrack(userID, playlistID, last, position)
+}
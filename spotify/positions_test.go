@@ -0,0 +1,41 @@
+package spotify
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/snikch/musicmanager/service"
+	"github.com/zmb3/spotify"
+)
+
+func fakeTrack(id string) spotify.FullTrack {
+	track := spotify.FullTrack{}
+	track.ID = spotify.ID(id)
+	track.Name = id
+	return track
+}
+
+func TestInsertTrack(t *testing.T) {
+	fake := service.NewFake("user")
+	playlist := fake.AddPlaylist("House: Vocal", fakeTrack("a"), fakeTrack("b"), fakeTrack("c"))
+	fake.Tracks["d"] = fakeTrack("d")
+	ctx := service.ContextWithService(context.Background(), fake)
+
+	err := InsertTrack(ctx, "user", playlist.ID, "d", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []spotify.ID{"a", "d", "b", "c"}
+	if !reflect.DeepEqual(fake.PlaylistTrackIDs[playlist.ID], expected) {
+		t.Fatalf("Expected %v, got %v", expected, fake.PlaylistTrackIDs[playlist.ID])
+	}
+
+	positions, err := PlaylistPositions(ctx, "user", playlist.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(positions["d"], []int{1}) || !reflect.DeepEqual(positions["c"], []int{3}) {
+		t.Fatalf("Expected d at 1 and c at 3, got %v", positions)
+	}
+}
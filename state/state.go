@@ -0,0 +1,153 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/snikch/api/fail"
+	"github.com/snikch/musicmanager/types"
+)
+
+const stateLoc = ".cache/state"
+
+// State is whether a recording is wanted, so that recordings that have been decided against are never suggested
+// again.
+type State string
+
+const (
+	Undecided State = "undecided"
+	Have      State = "have"
+	Want      State = "want"
+	DontWant  State = "dont-want"
+)
+
+// States are every state, in the order they're displayed.
+var States = []State{Have, Want, DontWant, Undecided}
+
+// ParseState returns the state with the supplied name.
+func ParseState(name string) (State, error) {
+	for _, state := range States {
+		if string(state) == name {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("unknown state %q, expected have, want, dont-want or undecided", name)
+}
+
+// Store is the state of every recording that has one. Recordings that aren't in the store are Undecided.
+type Store struct {
+	mu         sync.Mutex
+	Recordings map[types.RecordingKey]State
+}
+
+// Load reads the store from the cache, returning an empty store if there isn't one yet.
+func Load() (*Store, error) {
+	store := &Store{Recordings: map[types.RecordingKey]State{}}
+	contents, err := ioutil.ReadFile(stateLoc)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	err = json.Unmarshal(contents, store)
+	if err != nil {
+		return nil, fail.Trace(err)
+	}
+	if store.Recordings == nil {
+		store.Recordings = map[types.RecordingKey]State{}
+	}
+	return store, nil
+}
+
+// Save writes the store to the cache.
+func (store *Store) Save() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	contents, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fail.Trace(err)
+	}
+	err = os.MkdirAll(filepath.Dir(stateLoc), 0755)
+	if err != nil {
+		return fail.Trace(err)
+	}
+	return fail.Trace(ioutil.WriteFile(stateLoc, contents, 0644))
+}
+
+// Get returns the state of the recording.
+func (store *Store) Get(key types.RecordingKey) State {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if state, ok := store.Recordings[key]; ok {
+		return state
+	}
+	return Undecided
+}
+
+// Set sets the state of the recording, returning its previous state.
+func (store *Store) Set(key types.RecordingKey, state State) State {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	old, ok := store.Recordings[key]
+	if !ok {
+		old = Undecided
+	}
+	if state == Undecided {
+		delete(store.Recordings, key)
+	} else {
+		store.Recordings[key] = state
+	}
+	return old
+}
+
+// MarkHave sets every supplied recording to Have, returning how many changed. Recordings marked Don't Want are left
+// alone, as that was an explicit decision and the file may just not have been removed yet.
+func (store *Store) MarkHave(keys []types.RecordingKey) int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	changed := 0
+	for _, key := range keys {
+		state := store.Recordings[key]
+		if state == Have || state == DontWant {
+			continue
+		}
+		store.Recordings[key] = Have
+		changed++
+	}
+	return changed
+}
+
+// Counts returns the number of recordings in each state, excluding Undecided.
+func (store *Store) Counts() map[State]int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	counts := map[State]int{}
+	for _, state := range store.Recordings {
+		counts[state]++
+	}
+	return counts
+}
+
+type contextKey int
+
+const storeKey contextKey = iota
+
+// ContextWithStore returns a new context with the supplied state store.
+func ContextWithStore(ctx context.Context, store *Store) context.Context {
+	return context.WithValue(ctx, storeKey, store)
+}
+
+// ContextStore returns the state store for the supplied context, or nil if there isn't one.
+func ContextStore(ctx context.Context) *Store {
+	val := ctx.Value(storeKey)
+	if val != nil {
+		return val.(*Store)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/snikch/musicmanager/types"
+)
+
+func TestMarkHave(t *testing.T) {
+	store := &Store{Recordings: map[types.RecordingKey]State{}}
+	want := types.RecordingKeyForTitle("a", "one (Extended Mix)")
+	unwanted := types.RecordingKeyForTitle("a", "one (Radio Edit)")
+	undecided := types.RecordingKeyForTitle("b", "two")
+	store.Set(want, Want)
+	if old := store.Set(unwanted, DontWant); old != Undecided {
+		t.Fatalf("Expected a new recording to be undecided, got %s", old)
+	}
+
+	changed := store.MarkHave([]types.RecordingKey{want, unwanted, undecided})
+	if changed != 2 {
+		t.Fatalf("Expected 2 recordings to change, got %d", changed)
+	}
+	if store.Get(want) != Have || store.Get(undecided) != Have {
+		t.Fatalf("Expected local recordings to be have, got %v", store.Recordings)
+	}
+	if store.Get(unwanted) != DontWant {
+		t.Fatalf("Expected don't want to be left alone, got %s", store.Get(unwanted))
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := &Store{Recordings: map[types.RecordingKey]State{}}
+	key := types.RecordingKeyForTitle("a", "one (Radio Edit)")
+	store.Set(key, DontWant)
+	contents, err := json.Marshal(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Store{}
+	err = json.Unmarshal(contents, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Get(key) != DontWant {
+		t.Fatalf("Expected %v to be dont-want after a round trip, got %v", key, loaded.Recordings)
+	}
+}
@@ -0,0 +1,210 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Services a ServiceTrack can belong to.
+const (
+	ServiceLocal   = "local"
+	ServiceSpotify = "spotify"
+	ServiceITunes  = "itunes"
+)
+
+// RecordingType is a version of a song, such as "Extended Mix" or "Radio Edit". Titles with no mix name have the
+// Unlabelled type.
+type RecordingType string
+
+const (
+	RecordingUnlabelled RecordingType = ""
+	RecordingOriginal   RecordingType = "Original Mix"
+	RecordingExtended   RecordingType = "Extended Mix"
+	RecordingRadioEdit  RecordingType = "Radio Edit"
+	RecordingClub       RecordingType = "Club Mix"
+	RecordingDub        RecordingType = "Dub Mix"
+	// RecordingRemix and RecordingRework are by a remixer, which is part of the recording key.
+	RecordingRemix        RecordingType = "Remix"
+	RecordingRework       RecordingType = "Rework"
+	RecordingVIP          RecordingType = "VIP Mix"
+	RecordingInstrumental RecordingType = "Instrumental"
+)
+
+// RecordingKey identifies a single recording of a song.
+type RecordingKey struct {
+	Song    SongKey
+	Type    RecordingType
+	Remixer string
+}
+
+func (key RecordingKey) MarshalText() ([]byte, error) {
+	parts := []string{key.Song.Artist, key.Song.Title, string(key.Type)}
+	if key.Remixer != "" {
+		parts = append(parts, key.Remixer)
+	}
+	return json.Marshal(parts)
+}
+
+func (key *RecordingKey) UnmarshalText(content []byte) error {
+	parts := []string{}
+	err := json.Unmarshal(content, &parts)
+	if err != nil {
+		return err
+	}
+	if len(parts) != 3 && len(parts) != 4 {
+		return errors.New("RecordingKey.UnmarshalText: Requires three or four parts")
+	}
+	key.Song = SongKey{Artist: parts[0], Title: parts[1]}
+	key.Type = RecordingType(parts[2])
+	key.Remixer = ""
+	if len(parts) == 4 {
+		key.Remixer = parts[3]
+	}
+	return nil
+}
+
+// MixName returns the mix name of the recording as it's usually written, e.g. "Someone Remix".
+func (key RecordingKey) MixName() string {
+	return ParsedTitle{Type: key.Type, Remixer: key.Remixer}.MixName()
+}
+
+// String returns the key as it would be written as a title, such as "Artist - Song (Extended Mix)".
+func (key RecordingKey) String() string {
+	name := key.MixName()
+	if name == "" {
+		return key.Song.Artist + " - " + key.Song.Title
+	}
+	return key.Song.Artist + " - " + key.Song.Title + " (" + name + ")"
+}
+
+// RecordingKeyForTitle returns the recording key for an artist and full title, such as "Song (Extended Mix)".
+func RecordingKeyForTitle(artist, title string) RecordingKey {
+	parsed := ParseTitle(title)
+	return RecordingKey{Song: SongKey{Artist: artist, Title: parsed.Base}, Type: parsed.Type, Remixer: parsed.Remixer}
+}
+
+// Composition is a song, which may have many recordings. It's what the README calls a Song, named so it doesn't
+// clash with the Song tag interface.
+type Composition struct {
+	Key SongKey
+	// Recordings are keyed by mix name, so remixes by different remixers are different recordings.
+	Recordings map[string]*Recording
+	// Playlists are the names of the Spotify playlists any recording of the song is in.
+	Playlists []string
+}
+
+// Recording is a specific mix of a song, such as "Extended Mix" or "Radio Edit".
+type Recording struct {
+	Type    RecordingType
+	Remixer string `json:",omitempty"`
+	Tracks  []*Track
+}
+
+// Track is a specific recording, on a specific release.
+type Track struct {
+	Album     string
+	Instances []ServiceTrack
+}
+
+// ServiceTrack is a service's instance of a track. A service may have many instances of a single track.
+type ServiceTrack struct {
+	Service string
+	// ID is the Spotify ID, iTunes track ID or file path of the track.
+	ID      string
+	Title   string
+	Album   string
+	Year    int
+	Artists []string
+}
+
+// Catalog links the instances of every song across services. Compositions are keyed by the normalized artists and
+// title, so "A, B" and "A & B" are the same song, while each composition's Key is the song as it was first seen.
+type Catalog struct {
+	Compositions map[SongKey]*Composition
+}
+
+// NewCatalog returns an empty catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{Compositions: map[SongKey]*Composition{}}
+}
+
+// Add links the service track to the recording identified by the supplied key, creating the composition, recording
+// and release track as required. Instances on the same album (ignoring case) are linked to the same track.
+func (catalog *Catalog) Add(key RecordingKey, instance ServiceTrack) *Recording {
+	index := catalogKey(key.Song)
+	composition, ok := catalog.Compositions[index]
+	if !ok {
+		composition = &Composition{Key: key.Song, Recordings: map[string]*Recording{}}
+		catalog.Compositions[index] = composition
+	}
+	recording, ok := composition.Recordings[key.MixName()]
+	if !ok {
+		recording = &Recording{Type: key.Type, Remixer: key.Remixer}
+		composition.Recordings[key.MixName()] = recording
+	}
+	for _, track := range recording.Tracks {
+		if strings.EqualFold(track.Album, instance.Album) {
+			track.Instances = append(track.Instances, instance)
+			return recording
+		}
+	}
+	recording.Tracks = append(recording.Tracks, &Track{Album: instance.Album, Instances: []ServiceTrack{instance}})
+	return recording
+}
+
+// Composition returns the composition of the supplied song key, which may include a mix name in the title.
+func (catalog *Catalog) Composition(key SongKey) *Composition {
+	return catalog.Compositions[catalogKey(key)]
+}
+
+// catalogKey returns the key a song is stored under in the catalog, made from its normalized artists and title.
+func catalogKey(key SongKey) SongKey {
+	normalized := key.Normalize()
+	return SongKey{Artist: strings.Join(normalized.Artists, ", "), Title: normalized.Title}
+}
+
+// AddPlaylist records that a recording of the song is in the named playlist.
+func (composition *Composition) AddPlaylist(name string) {
+	for _, existing := range composition.Playlists {
+		if existing == name {
+			return
+		}
+	}
+	composition.Playlists = append(composition.Playlists, name)
+	sort.Strings(composition.Playlists)
+}
+
+// Instances returns every service track of the recording, across all releases.
+func (recording *Recording) Instances() []ServiceTrack {
+	instances := []ServiceTrack{}
+	for _, track := range recording.Tracks {
+		instances = append(instances, track.Instances...)
+	}
+	return instances
+}
+
+// LoadCatalog reads a catalog previously saved at the supplied location. A missing file is an empty catalog.
+func LoadCatalog(loc string) (*Catalog, error) {
+	catalog := NewCatalog()
+	contents, err := ioutil.ReadFile(loc)
+	if os.IsNotExist(err) {
+		return catalog, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return catalog, json.Unmarshal(contents, catalog)
+}
+
+// Save writes the catalog to the supplied location as json.
+func (catalog *Catalog) Save(loc string) error {
+	contents, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(loc, contents, 0644)
+}
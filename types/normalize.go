@@ -0,0 +1,198 @@
+package types
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// foldedRunes are the ASCII forms of accented and other letters that don't lowercase to ASCII.
+var foldedRunes = func() map[rune]string {
+	pairs := "àa áa âa ãa äa åa āa ăa ąa æae çc ćc čc ďd đd ðd èe ée êe ëe ēe ėe ęe ěe ğg ìi íi îi ïi īi įi ıi ķk " +
+		"ĺl ļl ľl łl ñn ńn ņn ňn òo óo ôo õo öo øo ōo őo œoe ŕr řr śs şs šs ßss ţt ťt ùu úu ûu üu ūu ůu űu ųu ýy ÿy " +
+		"źz żz žz þth"
+	folded := map[rune]string{}
+	for _, pair := range strings.Fields(pairs) {
+		runes := []rune(pair)
+		folded[runes[0]] = string(runes[1:])
+	}
+	return folded
+}()
+
+// Fold normalizes a string for comparison: lowercased, accents removed, "&" spelled "and", apostrophes dropped and
+// other punctuation treated as spaces.
+func Fold(s string) string {
+	folded := strings.Builder{}
+	for _, r := range strings.ToLower(s) {
+		if ascii, ok := foldedRunes[r]; ok {
+			folded.WriteString(ascii)
+			continue
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			folded.WriteRune(r)
+		case r == '\'' || r == '’':
+		case r == '&':
+			folded.WriteString(" and ")
+		default:
+			folded.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(folded.String()), " ")
+}
+
+// artistSeparatorRegex matches the separators between artists in an artist tag, including Spotify's ", ".
+var artistSeparatorRegex = regexp.MustCompile(`(?i)\s*(?:,|&|\+|/|;|\s(?:and|x|vs\.?|feat\.?|ft\.?|featuring|with)\s)\s*`)
+
+// titleFeatureRegex matches a featured artist that isn't in brackets, e.g. "Song feat. Singer".
+var titleFeatureRegex = regexp.MustCompile(`(?i)\s(?:feat\.?|ft\.?|featuring)\s+(.+)$`)
+
+// NormalizedKey is a song key normalized for matching across services, which each format artists, featured
+// artists, mix names and accents differently.
+type NormalizedKey struct {
+	// Artists are the folded names of every artist, including featured artists, sorted and unique.
+	Artists []string
+	// Title is the folded base title.
+	Title string
+	// Type is the recording type, with "Original Mix" treated as unlabelled as most services leave it off.
+	Type    RecordingType
+	Remixer string
+}
+
+// Normalize returns the normalized form of the key.
+func (key SongKey) Normalize() NormalizedKey {
+	parsed := ParseTitle(key.Title)
+	artists := splitArtists(key.Artist)
+	for _, residual := range strings.Split(parsed.Residual, "; ") {
+		if featureRegex.MatchString(residual) {
+			artists = append(artists, splitArtists(featureRegex.ReplaceAllString(residual, ""))...)
+		}
+	}
+	base := parsed.Base
+	if match := titleFeatureRegex.FindStringSubmatchIndex(base); match != nil {
+		artists = append(artists, splitArtists(base[match[2]:match[3]])...)
+		base = base[:match[0]]
+	}
+	normalized := NormalizedKey{Title: Fold(base), Type: parsed.Type, Remixer: Fold(parsed.Remixer)}
+	if normalized.Type == RecordingOriginal {
+		normalized.Type = RecordingUnlabelled
+	}
+	unique := map[string]bool{}
+	for _, artist := range artists {
+		if artist != "" && !unique[artist] {
+			unique[artist] = true
+			normalized.Artists = append(normalized.Artists, artist)
+		}
+	}
+	sort.Strings(normalized.Artists)
+	return normalized
+}
+
+func splitArtists(artists string) []string {
+	split := []string{}
+	for _, artist := range artistSeparatorRegex.Split(artists, -1) {
+		if folded := Fold(artist); folded != "" {
+			split = append(split, folded)
+		}
+	}
+	return split
+}
+
+// MatchScore returns how confident we are that two keys are the same recording, from 0 to 1. Titles are compared
+// by edit distance, artists as sets so order and featured artists don't matter (each artist only one side lists
+// costs a little), and different mixes of the same song score well below a match. Titles with different numbers,
+// e.g. "Nova Part 1" and "Nova Part 2", are different songs however close they are.
+func MatchScore(a, b NormalizedKey) float64 {
+	if !reflect.DeepEqual(titleNumbers(a.Title), titleNumbers(b.Title)) {
+		return 0
+	}
+	score := similarity(a.Title, b.Title) * artistOverlap(a.Artists, b.Artists)
+	if a.Type != b.Type || a.Remixer != b.Remixer {
+		score *= 0.7
+	}
+	return score
+}
+
+// sequenceWords come before the number of a part in a series, e.g. "Part II" or "Vol. Two".
+var sequenceWords = map[string]bool{"part": true, "pt": true, "vol": true, "volume": true, "chapter": true, "no": true}
+
+// titleNumbers returns the numbers in a folded title, along with the words numbering a part in a series.
+func titleNumbers(title string) []string {
+	numbers := []string{}
+	words := strings.Fields(title)
+	for i, word := range words {
+		if strings.IndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+			numbers = append(numbers, strings.TrimLeft(word, "0"))
+		} else if i > 0 && sequenceWords[words[i-1]] {
+			numbers = append(numbers, word)
+		}
+	}
+	return numbers
+}
+
+// artistOverlap scores two artist sets by the share of the smaller set in both, less 0.05 per artist in only one.
+func artistOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inA := map[string]bool{}
+	for _, artist := range a {
+		inA[artist] = true
+	}
+	both := 0
+	for _, artist := range b {
+		if inA[artist] {
+			both++
+		}
+	}
+	smaller := len(a)
+	if len(b) < smaller {
+		smaller = len(b)
+	}
+	score := float64(both)/float64(smaller) - 0.05*float64(len(a)+len(b)-2*both)
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// similarity returns 1 minus the edit distance between the strings relative to the longer one.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longer := len(ra)
+	if len(rb) > longer {
+		longer = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longer)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j] + 1
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
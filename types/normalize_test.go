@@ -0,0 +1,55 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		key      SongKey
+		expected NormalizedKey
+	}{
+		{SongKey{"Artist", "Song"}, NormalizedKey{Artists: []string{"artist"}, Title: "song"}},
+		{SongKey{"Röyksopp & Robyn", "Do It Again (Original Mix)"}, NormalizedKey{Artists: []string{"robyn", "royksopp"}, Title: "do it again"}},
+		{SongKey{"B, A", "Don’t Stop - Radio Edit"}, NormalizedKey{Artists: []string{"a", "b"}, Title: "dont stop", Type: RecordingRadioEdit}},
+		{SongKey{"A feat. B", "Song"}, NormalizedKey{Artists: []string{"a", "b"}, Title: "song"}},
+		{SongKey{"A", "Song (feat. B & C)"}, NormalizedKey{Artists: []string{"a", "b", "c"}, Title: "song"}},
+		{SongKey{"A", "Song ft. B"}, NormalizedKey{Artists: []string{"a", "b"}, Title: "song"}},
+		{SongKey{"A", "Song (Someone Remix)"}, NormalizedKey{Artists: []string{"a"}, Title: "song", Type: RecordingRemix, Remixer: "someone"}},
+	}
+	for _, test := range tests {
+		actual := test.key.Normalize()
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Normalize(%+v): expected %+v, got %+v", test.key, test.expected, actual)
+		}
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	tests := []struct {
+		local, remote SongKey
+		min, max      float64
+	}{
+		{SongKey{"A", "Song"}, SongKey{"A", "Song"}, 1, 1},
+		{SongKey{"a & b", "song (original mix)"}, SongKey{"B, A", "Song"}, 1, 1},
+		{SongKey{"A", "Song (feat. B)"}, SongKey{"A, B", "Song"}, 1, 1},
+		{SongKey{"Beyoncé", "Song"}, SongKey{"Beyonce", "Song"}, 1, 1},
+		{SongKey{"A", "Song"}, SongKey{"A, B", "Song"}, 0.9, 0.99},
+		{SongKey{"A", "Songs"}, SongKey{"A", "Song"}, 0.6, 0.9},
+		{SongKey{"A", "Song (Extended Mix)"}, SongKey{"A", "Song - Radio Edit"}, 0.6, 0.9},
+		{SongKey{"A", "Song"}, SongKey{"C", "Song"}, 0, 0},
+		{SongKey{"A", "Song"}, SongKey{"A", "Other"}, 0, 0.3},
+		{SongKey{"A", "Nova Part 2"}, SongKey{"a", "Nova (Part 2)"}, 1, 1},
+		{SongKey{"A", "Nova Part 1"}, SongKey{"A", "Nova Part 2"}, 0, 0},
+		{SongKey{"A", "Movement 1"}, SongKey{"A", "Movement 2"}, 0, 0},
+		{SongKey{"A", "Nova Part I"}, SongKey{"A", "Nova Part II"}, 0, 0},
+		{SongKey{"A", "Nova"}, SongKey{"A", "Nova 2"}, 0, 0},
+	}
+	for _, test := range tests {
+		score := MatchScore(test.local.Normalize(), test.remote.Normalize())
+		if score < test.min || score > test.max {
+			t.Errorf("MatchScore(%+v, %+v): expected between %.2f and %.2f, got %.2f", test.local, test.remote, test.min, test.max, score)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package types
 
 import (
+	"bytes"
 	"strings"
+	"time"
 
 	"github.com/bogem/id3v2"
 	id3 "github.com/mikkyang/id3-go"
+	v2 "github.com/mikkyang/id3-go/v2"
+	"github.com/snikch/musicmanager/flac"
+	"github.com/snikch/musicmanager/iff"
 	"github.com/snikch/musicmanager/itunes"
+	"github.com/snikch/musicmanager/mp4"
 	"github.com/zmb3/spotify"
 )
 
@@ -13,14 +19,32 @@ type File struct {
 	Song
 	Filename string
 	Dir      string
+	// Duration is the audio length, when it's known from the file's audio headers or the library index.
+	Duration time.Duration
+	// BitrateMode is "CBR", "VBR" or "ABR", and Bitrate the average bitrate in kbps, for MP3 files.
+	BitrateMode string
+	Bitrate     int
+	SampleRate  int
+}
+
+// Path returns the full location of the file on disk.
+func (file File) Path() string {
+	return file.Dir + "/" + file.Filename
 }
 
 type FileContexts map[SongKey]FileWithContext
 type FileWithContext struct {
 	File
-	SpotifyTrack     *spotify.FullTrack
+	SpotifyTrack *spotify.FullTrack
+	// SpotifyKey is the graph key of SpotifyTrack, which may differ from the file's own key. SpotifyScore is how
+	// closely it matched, 1 for ISRC matches and keys that are the same once normalized.
+	SpotifyKey       SongKey
+	SpotifyScore     float64
 	SpotifyPlaylists []spotify.SimplePlaylist
-	ITunesTrack      *itunes.Track
+	// SongPlaylists are the playlists other recordings of the same song are in, e.g. the radio edit of a local
+	// extended mix.
+	SongPlaylists []spotify.SimplePlaylist
+	ITunesTrack   *itunes.Track
 }
 
 type Song interface {
@@ -29,9 +53,12 @@ type Song interface {
 	Comment() string
 	Genre() string
 	Year() string
+	// ISRC is the International Standard Recording Code of the recording, from the TSRC frame.
+	ISRC() string
 	SetComment(string)
 	SetGenre(string)
 	SetYear(string)
+	SetISRC(string)
 	Save() error
 }
 
@@ -50,6 +77,22 @@ func (file ID3Wrapper) SetComment(comment string) {
 	panic("Cannot set comment")
 }
 
+func (file ID3Wrapper) ISRC() string {
+	for _, id := range []string{"TSRC", "TRC"} {
+		if frame, ok := file.File.Frame(id).(*v2.TextFrame); ok {
+			return frame.Text()
+		}
+	}
+	return ""
+}
+
+func (file ID3Wrapper) SetISRC(isrc string) {
+	file.File.DeleteFrames("TSRC")
+	if isrc != "" {
+		file.File.AddFrames(v2.NewTextFrame(v2.V23FrameTypeMap["TSRC"], isrc))
+	}
+}
+
 func (file ID3Wrapper) Save() error {
 	return file.Close()
 }
@@ -85,6 +128,18 @@ func (tag ID3V2Wrapper) SetComment(comment string) {
 	})
 }
 
+func (tag ID3V2Wrapper) ISRC() string {
+	return tag.Tag.GetTextFrame("TSRC").Text
+}
+
+func (tag ID3V2Wrapper) SetISRC(isrc string) {
+	if isrc == "" {
+		tag.Tag.DeleteFrames("TSRC")
+		return
+	}
+	tag.Tag.AddTextFrame("TSRC", tag.Tag.DefaultEncoding(), isrc)
+}
+
 type CleanWrapper struct {
 	Song
 }
@@ -104,3 +159,109 @@ func (wrapper CleanWrapper) Genre() string {
 func (wrapper CleanWrapper) Comment() string {
 	return strings.TrimRight(wrapper.Song.Comment(), "\x00")
 }
+
+func (wrapper CleanWrapper) ISRC() string {
+	return strings.TrimRight(wrapper.Song.ISRC(), "\x00")
+}
+
+// MP4Wrapper is a Song backed by the metadata atoms of an MP4 (.m4a) file.
+type MP4Wrapper struct {
+	*mp4.File
+}
+
+func (file MP4Wrapper) Artist() string {
+	return file.File.Text(mp4.Artist)
+}
+
+func (file MP4Wrapper) Title() string {
+	return file.File.Text(mp4.Title)
+}
+
+func (file MP4Wrapper) Comment() string {
+	return file.File.Text(mp4.Comment)
+}
+
+func (file MP4Wrapper) SetComment(comment string) {
+	file.File.SetText(mp4.Comment, comment)
+}
+
+// Year returns the year of the release date, which iTunes stores as a full timestamp on purchased files.
+func (file MP4Wrapper) Year() string {
+	year := file.File.Text(mp4.Year)
+	if len(year) > 4 {
+		return year[:4]
+	}
+	return year
+}
+
+func (file MP4Wrapper) SetYear(year string) {
+	file.File.SetText(mp4.Year, year)
+}
+
+// FLACWrapper is a Song backed by the Vorbis comments of a FLAC file.
+type FLACWrapper struct {
+	*flac.File
+}
+
+func (file FLACWrapper) Artist() string {
+	return strings.Join(file.File.Values("ARTIST"), ", ")
+}
+
+func (file FLACWrapper) Title() string {
+	return file.File.Get("TITLE")
+}
+
+func (file FLACWrapper) Comment() string {
+	return strings.Join(file.File.Values("COMMENT"), "\n")
+}
+
+// Genre returns every GENRE field, space separated like the tags written by tag-files.
+func (file FLACWrapper) Genre() string {
+	return strings.Join(file.File.Values("GENRE"), " ")
+}
+
+// Year returns the year of the DATE field, which may be a full date.
+func (file FLACWrapper) Year() string {
+	year := file.File.Get("DATE")
+	if len(year) > 4 {
+		return year[:4]
+	}
+	return year
+}
+
+func (file FLACWrapper) ISRC() string {
+	return file.File.Get("ISRC")
+}
+
+func (file FLACWrapper) SetComment(comment string) {
+	file.File.Set("COMMENT", comment)
+}
+
+func (file FLACWrapper) SetGenre(genre string) {
+	file.File.Set("GENRE", genre)
+}
+
+func (file FLACWrapper) SetYear(year string) {
+	file.File.Set("DATE", year)
+}
+
+func (file FLACWrapper) SetISRC(isrc string) {
+	file.File.Set("ISRC", isrc)
+}
+
+// ID3ChunkWrapper is a Song backed by the ID3 chunk of an AIFF or WAV file.
+type ID3ChunkWrapper struct {
+	ID3V2Wrapper
+	Container *iff.File
+}
+
+// Save writes the tag into the file's ID3 chunk, creating the chunk if the file didn't have one.
+func (wrapper ID3ChunkWrapper) Save() error {
+	tag := &bytes.Buffer{}
+	_, err := wrapper.Tag.WriteTo(tag)
+	if err != nil {
+		return err
+	}
+	wrapper.Container.SetID3(tag.Bytes())
+	return wrapper.Container.Save()
+}
@@ -0,0 +1,181 @@
+package types
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ParsedTitle is a title split into the song's base title and the parts describing the recording, e.g.
+// "Song (feat. Singer) [Someone Extended Remix]" has the base "Song", the type RecordingRemix, the remixer "Someone"
+// and the residual "Extended; feat. Singer".
+type ParsedTitle struct {
+	Base string
+	Type RecordingType
+	// Remixer is who made a remix or rework.
+	Remixer string
+	// Residual is anything in the mix name or trailing brackets that isn't part of the type or remixer, such as a
+	// featured artist, "Vocal" in "Extended Vocal Mix" or "2012 Remaster".
+	Residual string
+}
+
+// titleSegmentRegex matches the last bracketed or dash separated segment of a title.
+var titleSegmentRegex = regexp.MustCompile(`(?:\s+-\s+([^-()\[\]]+)|\s*\(([^()]*)\)|\s*\[([^\[\]]*)\])\s*$`)
+
+// featureRegex matches segments naming a featured artist.
+var featureRegex = regexp.MustCompile(`(?i)^(feat\.?|ft\.?|featuring)\s`)
+
+// remasterRegex matches segments describing a reissue rather than a recording.
+var remasterRegex = regexp.MustCompile(`(?i)\bremaster(ed)?\b`)
+
+// mixQualifiers are words that describe a mix rather than who made it, so "Someone Extended Remix" is remixed by
+// Someone.
+var mixQualifiers = map[string]bool{
+	"extended":     true,
+	"radio":        true,
+	"club":         true,
+	"dub":          true,
+	"vocal":        true,
+	"instrumental": true,
+	"original":     true,
+}
+
+// ParseTitle splits a title into its base title and recording. Trailing segments such as " - Radio Edit",
+// "(Original Mix)" or "[Someone Remix]" are parsed from the end until one that isn't a mix name, featured artist or
+// remaster is found, which is left in the base title, e.g. "Song (Part 2)". The first mix name from the end sets
+// the type, and any others are added to the residual.
+func ParseTitle(title string) ParsedTitle {
+	parsed := ParsedTitle{Base: strings.TrimSpace(title)}
+	residual := []string{}
+	typed := false
+	for {
+		match := titleSegmentRegex.FindStringSubmatch(parsed.Base)
+		if match == nil {
+			break
+		}
+		segment := strings.TrimSpace(match[1] + match[2] + match[3])
+		rest := strings.TrimSpace(parsed.Base[:len(parsed.Base)-len(match[0])])
+		if rest == "" {
+			break
+		}
+		if featureRegex.MatchString(segment) || remasterRegex.MatchString(segment) {
+			residual = append([]string{segment}, residual...)
+			parsed.Base = rest
+			continue
+		}
+		mix, ok := parseMixName(segment)
+		if !ok {
+			break
+		}
+		if typed {
+			residual = append([]string{segment}, residual...)
+		} else {
+			typed = true
+			parsed.Type = mix.Type
+			parsed.Remixer = mix.Remixer
+			if mix.Residual != "" {
+				residual = append([]string{mix.Residual}, residual...)
+			}
+		}
+		parsed.Base = rest
+	}
+	parsed.Residual = strings.Join(residual, "; ")
+	return parsed
+}
+
+// parseMixName parses a single mix name such as "Extended Vocal Mix" or "Someone Remix", returning false if the
+// name isn't a mix name. Mix names that aren't one of the recording types, such as "Vocal Mix", are their own type.
+func parseMixName(name string) (ParsedTitle, bool) {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return ParsedTitle{}, false
+	}
+	lower := make([]string, len(words))
+	for i, word := range words {
+		lower[i] = strings.ToLower(word)
+	}
+	has := func(word string) int {
+		for i := range lower {
+			if lower[i] == word {
+				return i
+			}
+		}
+		return -1
+	}
+	// others returns the words that aren't one of the supplied words.
+	others := func(skip ...string) string {
+		rest := []string{}
+		for i, word := range lower {
+			skipped := false
+			for _, s := range skip {
+				if word == s {
+					skipped = true
+				}
+			}
+			if !skipped {
+				rest = append(rest, words[i])
+			}
+		}
+		return strings.Join(rest, " ")
+	}
+	// byArtist splits the words before the type into the remixer and any qualifiers.
+	byArtist := func(recordingType RecordingType, i int) (ParsedTitle, bool) {
+		remixer := words[:i]
+		qualifiers := []string{}
+		for len(remixer) > 0 && mixQualifiers[strings.ToLower(remixer[len(remixer)-1])] {
+			qualifiers = append([]string{remixer[len(remixer)-1]}, qualifiers...)
+			remixer = remixer[:len(remixer)-1]
+		}
+		residual := strings.TrimSpace(strings.Join(append(qualifiers, words[i+1:]...), " "))
+		return ParsedTitle{Type: recordingType, Remixer: strings.Join(remixer, " "), Residual: residual}, true
+	}
+	last := lower[len(lower)-1]
+	switch {
+	case has("remix") >= 0:
+		return byArtist(RecordingRemix, has("remix"))
+	case has("rework") >= 0:
+		return byArtist(RecordingRework, has("rework"))
+	case has("re-work") >= 0:
+		return byArtist(RecordingRework, has("re-work"))
+	case has("vip") >= 0:
+		return ParsedTitle{Type: RecordingVIP, Residual: others("vip", "mix")}, true
+	case has("instrumental") >= 0:
+		return ParsedTitle{Type: RecordingInstrumental, Residual: others("instrumental", "mix", "version")}, true
+	case has("dub") >= 0:
+		return ParsedTitle{Type: RecordingDub, Residual: others("dub", "mix", "version")}, true
+	case has("radio") >= 0 && (last == "edit" || last == "mix" || last == "version" || last == "cut"):
+		return ParsedTitle{Type: RecordingRadioEdit, Residual: others("radio", "edit", "mix", "version", "cut")}, true
+	case last != "mix" && last != "version" && last != "edit":
+		return ParsedTitle{}, false
+	case has("extended") >= 0:
+		return ParsedTitle{Type: RecordingExtended, Residual: others("extended", "mix", "version", "edit")}, true
+	case has("club") >= 0:
+		return ParsedTitle{Type: RecordingClub, Residual: others("club", "mix", "version")}, true
+	case has("original") >= 0:
+		return ParsedTitle{Type: RecordingOriginal, Residual: others("original", "mix", "version")}, true
+	}
+	return ParsedTitle{Type: RecordingType(name)}, true
+}
+
+// MixName returns the mix name of the recording as it's usually written, e.g. "Someone Remix".
+func (parsed ParsedTitle) MixName() string {
+	if (parsed.Type == RecordingRemix || parsed.Type == RecordingRework) && parsed.Remixer != "" {
+		return parsed.Remixer + " " + string(parsed.Type)
+	}
+	return string(parsed.Type)
+}
+
+// BaseTitle strips any trailing mix name from a title, e.g. "Song - Radio Edit" and "Song (Extended Mix)" both
+// become "Song".
+func BaseTitle(title string) string {
+	return ParseTitle(title).Base
+}
+
+// Base returns the key with any mix name removed from the title, so different mixes of a song share a key.
+func (key SongKey) Base() SongKey {
+	return SongKey{Artist: key.Artist, Title: BaseTitle(key.Title)}
+}
+
+// Recording returns the key of the recording the title describes.
+func (key SongKey) Recording() RecordingKey {
+	return RecordingKeyForTitle(key.Artist, key.Title)
+}
@@ -0,0 +1,66 @@
+package types
+
+import "testing"
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected ParsedTitle
+	}{
+		{"Song", ParsedTitle{Base: "Song"}},
+		{"Song (Original Mix)", ParsedTitle{Base: "Song", Type: RecordingOriginal}},
+		{"Song - Original", ParsedTitle{Base: "Song - Original"}},
+		{"Song - Extended Mix", ParsedTitle{Base: "Song", Type: RecordingExtended}},
+		{"Song [Extended Version]", ParsedTitle{Base: "Song", Type: RecordingExtended}},
+		{"Song - Radio Edit", ParsedTitle{Base: "Song", Type: RecordingRadioEdit}},
+		{"Song (Radio Version)", ParsedTitle{Base: "Song", Type: RecordingRadioEdit}},
+		{"Song (Club Mix)", ParsedTitle{Base: "Song", Type: RecordingClub}},
+		{"Song (Dub)", ParsedTitle{Base: "Song", Type: RecordingDub}},
+		{"Song - Extended Dub Mix", ParsedTitle{Base: "Song", Type: RecordingDub, Residual: "Extended"}},
+		{"Song (Someone Remix)", ParsedTitle{Base: "Song", Type: RecordingRemix, Remixer: "Someone"}},
+		{"Song - Some One Extended Remix", ParsedTitle{Base: "Song", Type: RecordingRemix, Remixer: "Some One", Residual: "Extended"}},
+		{"Song (Remix)", ParsedTitle{Base: "Song", Type: RecordingRemix}},
+		{"Song (Someone Rework)", ParsedTitle{Base: "Song", Type: RecordingRework, Remixer: "Someone"}},
+		{"Song (Someone Re-Work)", ParsedTitle{Base: "Song", Type: RecordingRework, Remixer: "Someone"}},
+		{"Song (VIP)", ParsedTitle{Base: "Song", Type: RecordingVIP}},
+		{"Song - VIP Mix", ParsedTitle{Base: "Song", Type: RecordingVIP}},
+		{"Song (Instrumental)", ParsedTitle{Base: "Song", Type: RecordingInstrumental}},
+		{"Song (Extended Vocal Mix)", ParsedTitle{Base: "Song", Type: RecordingExtended, Residual: "Vocal"}},
+		{"Song (Vocal Mix)", ParsedTitle{Base: "Song", Type: "Vocal Mix"}},
+		{"Song (feat. Singer)", ParsedTitle{Base: "Song", Residual: "feat. Singer"}},
+		{"Song (feat. Singer) [Extended Mix]", ParsedTitle{Base: "Song", Type: RecordingExtended, Residual: "feat. Singer"}},
+		{"Song - 2012 Remaster", ParsedTitle{Base: "Song", Residual: "2012 Remaster"}},
+		{"Song (Part 2)", ParsedTitle{Base: "Song (Part 2)"}},
+		{"Song (Part 2) (Radio Edit)", ParsedTitle{Base: "Song (Part 2)", Type: RecordingRadioEdit}},
+		{"Song (Original Mix) - Radio Edit", ParsedTitle{Base: "Song", Type: RecordingRadioEdit, Residual: "Original Mix"}},
+		{"Re-Up - Extended Mix", ParsedTitle{Base: "Re-Up", Type: RecordingExtended}},
+		{"(Extended Mix)", ParsedTitle{Base: "(Extended Mix)"}},
+	}
+	for _, test := range tests {
+		actual := ParseTitle(test.title)
+		if actual != test.expected {
+			t.Errorf("ParseTitle(%q): expected %+v, got %+v", test.title, test.expected, actual)
+		}
+	}
+}
+
+func TestRecordingKeyText(t *testing.T) {
+	for _, title := range []string{"Song (Extended Mix)", "Song (Someone Remix)"} {
+		key := RecordingKeyForTitle("Artist", title)
+		text, err := key.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := RecordingKey{}
+		err = actual.UnmarshalText(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != key {
+			t.Errorf("Expected %+v after a round trip, got %+v", key, actual)
+		}
+		if key.String() != "Artist - "+title {
+			t.Errorf("Expected %q, got %q", "Artist - "+title, key.String())
+		}
+	}
+}